@@ -3,11 +3,13 @@ package database
 import (
 	"database/sql"
 
+	"common/tracing"
+
 	_ "github.com/lib/pq"
 )
 
 func Connect(databaseURL string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+	db, err := tracing.OpenDB("postgres", databaseURL)
 	if err != nil {
 		return nil, err
 	}
@@ -18,7 +18,8 @@ func Migrate(db *sql.DB) error {
 	INSERT INTO user_roles (name, description) 
 	VALUES 
 		('user', 'Обычный пользователь'),
-		('police', 'Сотрудник полиции')
+		('police', 'Сотрудник полиции'),
+		('admin', 'Администратор')
 	ON CONFLICT (name) DO NOTHING;`
 
 	if _, err := db.Exec(insertRoles); err != nil {
@@ -42,5 +43,199 @@ func Migrate(db *sql.DB) error {
 		return err
 	}
 
+	addSessionsRevokedAt := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMP;`
+
+	if _, err := db.Exec(addSessionsRevokedAt); err != nil {
+		return err
+	}
+
+	createRefreshTokensTable := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);`
+
+	if _, err := db.Exec(createRefreshTokensTable); err != nil {
+		return err
+	}
+
+	createRevokedTokensTable := `
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti VARCHAR(64) PRIMARY KEY,
+		expires_at TIMESTAMP NOT NULL
+	);`
+
+	if _, err := db.Exec(createRevokedTokensTable); err != nil {
+		return err
+	}
+
+	createPermissionsTables := `
+	CREATE TABLE IF NOT EXISTS permissions (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) UNIQUE NOT NULL,
+		description TEXT
+	);
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		role_id INTEGER NOT NULL REFERENCES user_roles(id) ON DELETE CASCADE,
+		permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+		PRIMARY KEY (role_id, permission_id)
+	);`
+
+	if _, err := db.Exec(createPermissionsTables); err != nil {
+		return err
+	}
+
+	insertPermissions := `
+	INSERT INTO permissions (name, description)
+	VALUES
+		('requests:review', 'Просмотр и рассмотрение заявок на полет'),
+		('zones:write', 'Создание и изменение запретных зон'),
+		('drones:force_stop', 'Остановка, посадка и блокировка чужих дронов'),
+		('airspace:lockdown', 'Экстренное закрытие воздушного пространства'),
+		('roles:manage', 'Управление ролями и правами пользователей')
+	ON CONFLICT (name) DO NOTHING;`
+
+	if _, err := db.Exec(insertPermissions); err != nil {
+		return err
+	}
+
+	// Права по умолчанию назначаются только при первом запуске, чтобы не
+	// перезаписывать изменения, сделанные администратором.
+	seedRolePermissions := `
+	INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.id, p.id
+	FROM user_roles r
+	JOIN permissions p ON (r.name, p.name) IN (
+		('police', 'requests:review'),
+		('police', 'zones:write'),
+		('police', 'drones:force_stop'),
+		('police', 'airspace:lockdown'),
+		('admin', 'requests:review'),
+		('admin', 'zones:write'),
+		('admin', 'drones:force_stop'),
+		('admin', 'airspace:lockdown'),
+		('admin', 'roles:manage')
+	)
+	WHERE NOT EXISTS (SELECT 1 FROM role_permissions)
+	ON CONFLICT DO NOTHING;`
+
+	if _, err := db.Exec(seedRolePermissions); err != nil {
+		return err
+	}
+
+	createRoleChangesTable := `
+	CREATE TABLE IF NOT EXISTS role_changes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		old_role_id INTEGER REFERENCES user_roles(id),
+		new_role_id INTEGER NOT NULL REFERENCES user_roles(id),
+		changed_by INTEGER REFERENCES users(id),
+		source VARCHAR(20) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_role_changes_user_id ON role_changes(user_id);`
+
+	if _, err := db.Exec(createRoleChangesTable); err != nil {
+		return err
+	}
+
+	createRoleInvitationsTable := `
+	CREATE TABLE IF NOT EXISTS role_invitations (
+		id SERIAL PRIMARY KEY,
+		code_hash VARCHAR(64) UNIQUE NOT NULL,
+		role_id INTEGER NOT NULL REFERENCES user_roles(id),
+		created_by INTEGER NOT NULL REFERENCES users(id),
+		expires_at TIMESTAMP NOT NULL,
+		used_by INTEGER REFERENCES users(id),
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createRoleInvitationsTable); err != nil {
+		return err
+	}
+
+	createPasswordResetTokensTable := `
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);`
+
+	if _, err := db.Exec(createPasswordResetTokensTable); err != nil {
+		return err
+	}
+
+	createLoginThrottleTable := `
+	CREATE TABLE IF NOT EXISTS login_throttle (
+		key VARCHAR(320) PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		window_start TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		locked_until TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createLoginThrottleTable); err != nil {
+		return err
+	}
+
+	createAPIKeysTable := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		prefix VARCHAR(16) UNIQUE NOT NULL,
+		key_hash VARCHAR(64) UNIQUE NOT NULL,
+		scopes TEXT[] NOT NULL,
+		expires_at TIMESTAMP,
+		last_used_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);`
+
+	if _, err := db.Exec(createAPIKeysTable); err != nil {
+		return err
+	}
+
+	createTwoFactorTables := `
+	CREATE TABLE IF NOT EXISTS user_totp (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		secret VARCHAR(64) NOT NULL,
+		enabled_at TIMESTAMP,
+		last_used_step BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		used_at TIMESTAMP,
+		UNIQUE (user_id, code_hash)
+	);
+	CREATE TABLE IF NOT EXISTS login_challenges (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createTwoFactorTables); err != nil {
+		return err
+	}
+
 	return nil
 }
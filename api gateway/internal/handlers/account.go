@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"api-gateway/internal/models"
+	"api-gateway/internal/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (h *AuthHandler) GetMe(c *gin.Context) {
+	var user models.User
+	if err := user.GetByID(c.Request.Context(), h.db, c.GetInt("user_id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AuthHandler) UpdateMe(c *gin.Context) {
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := user.GetByID(c.Request.Context(), h.db, c.GetInt("user_id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	user.FullName = req.FullName
+	user.Address = req.Address
+	user.Phone = req.Phone
+
+	if err := user.UpdateProfile(c.Request.Context(), h.db); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении профиля"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword меняет пароль и завершает все сессии пользователя.
+// Текущему клиенту сразу выдается новая пара токенов.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Пароль должен содержать минимум 6 символов"})
+		return
+	}
+
+	var user models.User
+	if err := user.GetByID(c.Request.Context(), h.db, c.GetInt("user_id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный текущий пароль"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при хешировании пароля"})
+		return
+	}
+
+	if err := models.UpdatePassword(c.Request.Context(), h.db, user.ID, string(hashedPassword)); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при смене пароля"})
+		return
+	}
+
+	if err := models.RevokeAllSessions(c.Request.Context(), h.db, user.ID); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессий"})
+		return
+	}
+
+	response, err := h.issueTokens(c.Request.Context(), user)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ForgotPassword отправляет письмо со ссылкой для сброса пароля. Ответ
+// не зависит от того, зарегистрирован ли email, чтобы не раскрывать
+// список пользователей.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "Если email зарегистрирован, на него отправлена ссылка для сброса пароля"}
+
+	var user models.User
+	if err := user.GetByEmail(c.Request.Context(), h.db, req.Email); err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токена сброса"})
+		return
+	}
+
+	reset := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(h.passwordResetTTL),
+	}
+	if err := reset.Create(c.Request.Context(), h.db); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании токена сброса"})
+		return
+	}
+
+	text := fmt.Sprintf("Здравствуйте, %s!\n\nДля сброса пароля перейдите по ссылке:\n%s?token=%s\n\n"+
+		"Ссылка действует %s и может быть использована один раз. Если вы не запрашивали сброс, проигнорируйте это письмо.",
+		user.FullName, h.passwordResetURL, token, h.passwordResetTTL)
+
+	// Ошибка отправки не меняет ответ: иначе по ней можно было бы узнать,
+	// что email зарегистрирован.
+	if err := h.mailer.Send(user.Email, "Сброс пароля", text); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to send password reset email", "user_id", user.ID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Пароль должен содержать минимум 6 символов"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при хешировании пароля"})
+		return
+	}
+
+	if _, err := models.ResetPassword(c.Request.Context(), h.db, utils.HashToken(req.Token), string(hashedPassword)); err != nil {
+		if errors.Is(err, models.ErrPasswordResetInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ссылка для сброса пароля недействительна или устарела"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сбросе пароля"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль изменен. Войдите с новым паролем"})
+}
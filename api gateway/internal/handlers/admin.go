@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"api-gateway/internal/models"
+	"api-gateway/internal/utils"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultInvitationTTL = 72 * time.Hour
+
+type AdminHandler struct {
+	db *sql.DB
+}
+
+func NewAdminHandler(db *sql.DB) *AdminHandler {
+	return &AdminHandler{db: db}
+}
+
+func (h *AdminHandler) ChangeRole(c *gin.Context) {
+	var req models.ChangeRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetInt("user_id")
+	if req.UserID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменить собственную роль"})
+		return
+	}
+
+	var role models.UserRole
+	if err := role.GetByID(c.Request.Context(), h.db, req.RoleID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Роль не найдена"})
+		return
+	}
+
+	var user models.User
+	if err := user.GetByID(c.Request.Context(), h.db, req.UserID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	if err := models.ChangeUserRole(c.Request.Context(), h.db, req.UserID, req.RoleID, &adminID, models.RoleChangeSourceAdmin, req.Reason); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при изменении роли"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":   req.UserID,
+		"role_id":   role.ID,
+		"role_name": role.Name,
+	})
+}
+
+func (h *AdminHandler) CreateInvitation(c *gin.Context) {
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.UserRole
+	if err := role.GetByID(c.Request.Context(), h.db, req.RoleID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Роль не найдена"})
+		return
+	}
+
+	ttl := defaultInvitationTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	code, err := utils.RandomToken(16)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации кода приглашения"})
+		return
+	}
+
+	invitation := models.RoleInvitation{
+		CodeHash:  utils.HashToken(code),
+		RoleID:    role.ID,
+		CreatedBy: c.GetInt("user_id"),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if err := invitation.Create(c.Request.Context(), h.db); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании приглашения"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateInvitationResponse{
+		Code:       code,
+		Invitation: invitation,
+	})
+}
+
+func (h *AdminHandler) GetRoleChanges(c *gin.Context) {
+	userID := 0
+	if value := c.Query("user_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный user_id"})
+			return
+		}
+		userID = id
+	}
+
+	changes, err := models.GetRoleChanges(c.Request.Context(), h.db, userID)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении истории ролей"})
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+func (h *AdminHandler) GetRoles(c *gin.Context) {
+	roles, err := models.GetRolesWithPermissions(c.Request.Context(), h.db)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении ролей"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *AdminHandler) GetPermissions(c *gin.Context) {
+	permissions, err := models.GetPermissions(c.Request.Context(), h.db)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении прав"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+func (h *AdminHandler) CreateRole(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.validatePermissions(c, req.Permissions) {
+		return
+	}
+
+	var existing models.UserRole
+	if err := existing.GetByName(c.Request.Context(), h.db, req.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Роль с таким именем уже существует"})
+		return
+	}
+
+	role := models.UserRole{Name: req.Name, Description: req.Description}
+	if err := role.Create(c.Request.Context(), h.db); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании роли"})
+		return
+	}
+
+	if err := models.SetRolePermissions(c.Request.Context(), h.db, role.ID, req.Permissions); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при назначении прав роли"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.RoleWithPermissions{UserRole: role, Permissions: req.Permissions})
+}
+
+func (h *AdminHandler) SetRolePermissions(c *gin.Context) {
+	var req models.SetRolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.UserRole
+	if err := role.GetByID(c.Request.Context(), h.db, req.RoleID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Роль не найдена"})
+		return
+	}
+
+	if !h.validatePermissions(c, req.Permissions) {
+		return
+	}
+
+	if err := models.SetRolePermissions(c.Request.Context(), h.db, role.ID, req.Permissions); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при назначении прав роли"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RoleWithPermissions{UserRole: role, Permissions: req.Permissions})
+}
+
+func (h *AdminHandler) validatePermissions(c *gin.Context, permissions []string) bool {
+	unknown, err := models.UnknownPermissions(c.Request.Context(), h.db, permissions)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке прав"})
+		return false
+	}
+
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестные права", "permissions": unknown})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"api-gateway/internal/models"
+	"api-gateway/internal/utils"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	db *sql.DB
+}
+
+func NewAPIKeyHandler(db *sql.DB) *APIKeyHandler {
+	return &APIKeyHandler{db: db}
+}
+
+// Create выпускает API-ключ. Сам ключ возвращается только в этом ответе,
+// в базе хранится его хеш и открытый префикс для опознания.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Необходимо указать хотя бы одну область действия"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":        "Неизвестная область действия: " + scope,
+				"valid_scopes": models.APIKeyScopes,
+			})
+			return
+		}
+	}
+
+	prefixPart, err := utils.RandomToken(4)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании ключа"})
+		return
+	}
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании ключа"})
+		return
+	}
+
+	prefix := models.APIKeyPrefix + prefixPart
+	key := prefix + "_" + secret
+
+	apiKey := models.APIKey{
+		UserID:  c.GetInt("user_id"),
+		Name:    req.Name,
+		Prefix:  prefix,
+		KeyHash: utils.HashToken(key),
+		Scopes:  req.Scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := apiKey.Create(c.Request.Context(), h.db); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании ключа"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateAPIKeyResponse{Key: key, APIKey: apiKey})
+}
+
+func (h *APIKeyHandler) List(c *gin.Context) {
+	keys, err := models.GetUserAPIKeys(c.Request.Context(), h.db, c.GetInt("user_id"))
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении ключей"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	var req models.RevokeAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revoked, err := models.RevokeAPIKey(c.Request.Context(), h.db, c.GetInt("user_id"), req.ID)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отзыве ключа"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ключ не найден или уже отозван"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ключ отозван"})
+}
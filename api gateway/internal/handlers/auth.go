@@ -1,24 +1,69 @@
 package handlers
 
 import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/mailer"
+	"api-gateway/internal/metrics"
 	"api-gateway/internal/models"
 	"api-gateway/internal/utils"
+	"context"
 	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const minPasswordLength = 6
+
+// dummyPasswordHash сверяется с паролем, когда пользователя с таким email
+// нет: ответ занимает столько же времени, сколько при неверном пароле, и по
+// нему нельзя узнать, зарегистрирован ли email. Стоимость совпадает с
+// bcrypt.DefaultCost, с которой хешируются пароли.
+const dummyPasswordHash = "$2a$10$Pr.IlOfM15kMO3F6qZio6OW1oqVeQneqtJBXH70o.yulHvuex8sH."
+
 type AuthHandler struct {
-	db        *sql.DB
-	jwtSecret string
+	db               *sql.DB
+	keys             *utils.KeySet
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	mailer           mailer.Mailer
+	passwordResetTTL time.Duration
+	passwordResetURL string
+
+	loginMaxAttempts   int
+	loginIPMaxAttempts int
+	loginWindow        time.Duration
+	loginLockout       time.Duration
+
+	totpIssuer        string
+	loginChallengeTTL time.Duration
 }
 
-func NewAuthHandler(db *sql.DB, jwtSecret string) *AuthHandler {
+func NewAuthHandler(db *sql.DB, cfg *config.Config, keys *utils.KeySet, m mailer.Mailer) *AuthHandler {
 	return &AuthHandler{
-		db:        db,
-		jwtSecret: jwtSecret,
+		db:               db,
+		keys:             keys,
+		accessTokenTTL:   cfg.AccessTokenTTL,
+		refreshTokenTTL:  cfg.RefreshTokenTTL,
+		mailer:           m,
+		passwordResetTTL: cfg.PasswordResetTTL,
+		passwordResetURL: cfg.PasswordResetURL,
+
+		loginMaxAttempts:   cfg.LoginMaxAttempts,
+		loginIPMaxAttempts: cfg.LoginIPMaxAttempts,
+		loginWindow:        cfg.LoginWindow,
+		loginLockout:       cfg.LoginLockout,
+
+		totpIssuer:        cfg.TOTPIssuer,
+		loginChallengeTTL: cfg.LoginChallengeTTL,
 	}
 }
 
@@ -29,58 +74,93 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	if len(req.Password) < 6 {
+	if len(req.Password) < minPasswordLength {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Пароль должен содержать минимум 6 символов"})
 		return
 	}
 
 	var existingUser models.User
-	if err := existingUser.GetByEmail(h.db, req.Email); err == nil {
+	if err := existingUser.GetByEmail(c.Request.Context(), h.db, req.Email); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Пользователь с таким email уже существует"})
 		return
 	}
 
+	var inviteHash string
+	if req.InviteCode != "" {
+		inviteHash = utils.HashToken(req.InviteCode)
+		valid, err := models.IsInvitationValid(c.Request.Context(), h.db, inviteHash)
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке приглашения"})
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Недействительный код приглашения"})
+			return
+		}
+	}
+
+	var userRole models.UserRole
+	if err := userRole.GetByName(c.Request.Context(), h.db, models.RoleUser); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении роли пользователя"})
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при хешировании пароля"})
 		return
 	}
 
-	if req.RoleID == 0 {
-		req.RoleID = 1
-	}
-
 	user := models.User{
 		FullName:     req.FullName,
 		Email:        req.Email,
 		Address:      req.Address,
 		Phone:        req.Phone,
 		PasswordHash: string(hashedPassword),
-		RoleID:       req.RoleID,
+		RoleID:       userRole.ID,
 	}
 
-	if err := user.Create(h.db); err != nil {
+	if err := user.Create(c.Request.Context(), h.db, inviteHash); err != nil {
+		// Приглашение могли использовать между проверкой и созданием.
+		if errors.Is(err, models.ErrInvitationInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Недействительный код приглашения"})
+			return
+		}
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при создании пользователя"})
 		return
 	}
 
-	if err := user.GetByID(h.db, user.ID); err != nil {
+	if err := user.GetByID(c.Request.Context(), h.db, user.ID); err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении данных пользователя"})
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.ID, user.RoleID, h.jwtSecret)
+	// Роль из приглашения может требовать 2FA: тогда токены выдаются только
+	// после подключения аутентификатора, как при входе.
+	challenge, err := h.loginChallenge(c.Request.Context(), user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке 2FA"})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusCreated, challenge)
 		return
 	}
 
-	user.PasswordHash = ""
+	response, err := h.issueTokens(c.Request.Context(), user)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
+		return
+	}
 
-	c.JSON(http.StatusCreated, models.LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -90,27 +170,222 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	ipKey := "ip:" + c.ClientIP()
+	accountKey := "email:" + strings.ToLower(req.Email)
+
+	lockedUntil, err := models.LoginLockedUntil(c.Request.Context(), h.db, ipKey, accountKey)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке блокировки входа"})
+		return
+	}
+	if !lockedUntil.IsZero() {
+		respondLocked(c, lockedUntil)
+		return
+	}
+
 	var user models.User
-	if err := user.GetByEmail(h.db, req.Email); err != nil {
+	if err := user.GetByEmail(c.Request.Context(), h.db, req.Email); err != nil {
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(req.Password))
+		h.recordLoginFailure(c.Request.Context(), ipKey, accountKey)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверные учетные данные"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		h.recordLoginFailure(c.Request.Context(), ipKey, accountKey)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверные учетные данные"})
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.ID, user.RoleID, h.jwtSecret)
+	if err := models.ResetLoginFailures(c.Request.Context(), h.db, accountKey); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to reset login failures", "key", accountKey, "error", err)
+	}
+
+	challenge, err := h.loginChallenge(c.Request.Context(), user)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке 2FA"})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	response, err := h.issueTokens(c.Request.Context(), user)
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
 		return
 	}
 
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var token models.RefreshToken
+	if err := token.GetByHash(c.Request.Context(), h.db, utils.HashToken(req.RefreshToken)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Недействительный refresh-токен"})
+		return
+	}
+
+	if token.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Срок действия refresh-токена истек"})
+		return
+	}
+
+	rotated, err := token.Revoke(c.Request.Context(), h.db)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении токена"})
+		return
+	}
+
+	// Повторное использование уже обмененного токена означает, что он
+	// утек: завершаем все сессии пользователя.
+	if !rotated {
+		if err := models.RevokeAllSessions(c.Request.Context(), h.db, token.UserID); err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при обновлении токена"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh-токен уже использован. Все сессии завершены"})
+		return
+	}
+
+	var user models.User
+	if err := user.GetByID(c.Request.Context(), h.db, token.UserID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	// Роль могла стать повышенной после входа: без 2FA сессию не продлеваем.
+	if missing, err := models.MissingTwoFactor(c.Request.Context(), h.db, user.ID, user.RoleID); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке 2FA"})
+		return
+	} else if missing {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Для вашей роли обязательна 2FA. Войдите заново, чтобы подключить аутентификатор"})
+		return
+	}
+
+	response, err := h.issueTokens(c.Request.Context(), user)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetInt("user_id")
+
+	if req.All {
+		if err := models.RevokeAllSessions(c.Request.Context(), h.db, userID); err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессий"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Все сессии завершены"})
+		return
+	}
+
+	jti := c.GetString("token_id")
+	expiresAt := c.GetTime("token_expires_at")
+	if err := models.RevokeAccessToken(c.Request.Context(), h.db, jti, expiresAt); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессии"})
+		return
+	}
+
+	if req.RefreshToken != "" {
+		var token models.RefreshToken
+		if err := token.GetByHash(c.Request.Context(), h.db, utils.HashToken(req.RefreshToken)); err == nil && token.UserID == userID {
+			if _, err := token.Revoke(c.Request.Context(), h.db); err != nil {
+				c.Error(err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при завершении сессии"})
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
+
+// JWKS публикует открытые ключи, чтобы сервисы могли сами проверять токены.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
+
+// recordLoginFailure учитывает неудачу отдельно для IP и для аккаунта:
+// перебор паролей одного аккаунта и перебор аккаунтов с одного адреса
+// блокируются независимо.
+func (h *AuthHandler) recordLoginFailure(ctx context.Context, ipKey, accountKey string) {
+	metrics.LoginFailures.WithLabelValues(metrics.LoginInvalidCredentials).Inc()
+
+	if err := models.RecordLoginFailure(ctx, h.db, ipKey, h.loginIPMaxAttempts, h.loginWindow, h.loginLockout); err != nil {
+		slog.ErrorContext(ctx, "Failed to record login failure", "key", ipKey, "error", err)
+	}
+	if err := models.RecordLoginFailure(ctx, h.db, accountKey, h.loginMaxAttempts, h.loginWindow, h.loginLockout); err != nil {
+		slog.ErrorContext(ctx, "Failed to record login failure", "key", accountKey, "error", err)
+	}
+}
+
+func respondLocked(c *gin.Context, lockedUntil time.Time) {
+	retryAfter := int(math.Ceil(time.Until(lockedUntil).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	metrics.LoginFailures.WithLabelValues(metrics.LoginLocked).Inc()
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Слишком много неудачных попыток входа. Повторите позже"})
+}
+
+// issueTokens выдает пару access/refresh токенов для пользователя.
+func (h *AuthHandler) issueTokens(ctx context.Context, user models.User) (*models.LoginResponse, error) {
+	permissions, err := models.GetRolePermissions(ctx, h.db, user.RoleID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := utils.GenerateJWT(user.ID, user.RoleID, permissions, h.keys, h.accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(h.refreshTokenTTL),
+	}
+	if err := stored.Create(ctx, h.db); err != nil {
+		return nil, err
+	}
+
 	user.PasswordHash = ""
 
-	c.JSON(http.StatusOK, models.LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	return &models.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.accessTokenTTL.Seconds()),
+		User:         user,
+	}, nil
 }
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"api-gateway/internal/proxy"
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version задается при сборке:
+// go build -ldflags "-X api-gateway/internal/handlers.Version=1.2.3"
+var Version = "dev"
+
+const healthCheckTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	db      *sql.DB
+	proxy   *proxy.ProxyHandler
+	started time.Time
+}
+
+func NewHealthHandler(db *sql.DB, proxyHandler *proxy.ProxyHandler) *HealthHandler {
+	return &HealthHandler{db: db, proxy: proxyHandler, started: time.Now()}
+}
+
+// Healthz - процесс жив и отвечает.
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"service": "gateway",
+		"version": Version,
+		"uptime":  h.uptime(),
+	})
+}
+
+// Readyz - шлюз может обслуживать запросы: база доступна и у каждого
+// сервиса есть хотя бы один исправный экземпляр.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	checks := gin.H{"database": h.pingDatabase(c.Request.Context())}
+	ready := checks["database"] == "ok"
+
+	for name, service := range h.proxy.Status() {
+		checks[name] = service.Status
+		if service.Status == "down" {
+			ready = false
+		}
+	}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(code, gin.H{
+		"status":  status,
+		"service": "gateway",
+		"version": Version,
+		"uptime":  h.uptime(),
+		"checks":  checks,
+	})
+}
+
+// Status - сводка по шлюзу и всем сервисам: состояние и версии экземпляров
+// по результатам последних проверок. Доступна только администраторам.
+func (h *HealthHandler) Status(c *gin.Context) {
+	database := h.pingDatabase(c.Request.Context())
+	services := h.proxy.Status()
+
+	overall := "ok"
+	if database != "ok" {
+		overall = "down"
+	}
+	for _, service := range services {
+		if service.Status != "up" && overall == "ok" {
+			overall = "degraded"
+		}
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"status": overall,
+		"gateway": gin.H{
+			"version":  Version,
+			"uptime":   h.uptime(),
+			"database": database,
+		},
+		"services": services,
+	})
+}
+
+func (h *HealthHandler) pingDatabase(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	// Текст ошибки раскрывает адрес базы, он только в логе.
+	if err := h.db.PingContext(ctx); err != nil {
+		slog.ErrorContext(ctx, "Database health check failed", "error", err)
+		return "error"
+	}
+	return "ok"
+}
+
+func (h *HealthHandler) uptime() string {
+	return time.Since(h.started).Round(time.Second).String()
+}
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/models"
+	"api-gateway/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recoveryCodeCount = 10
+
+// LoginTwoFactor - второй шаг входа: проверяет TOTP-код или код
+// восстановления по challenge-токену, выданному Login. Если аутентификатор
+// подключался в рамках этого входа, здесь же выдаются коды восстановления.
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, ok := h.loadChallenge(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+	if h.secondFactorLocked(c, challenge.UserID) {
+		return
+	}
+
+	totp, err := models.GetUserTOTP(c.Request.Context(), h.db, challenge.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Сначала подключите аутентификатор через /auth/login/2fa/enroll"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке кода"})
+		return
+	}
+
+	var valid bool
+	if req.RecoveryCode != "" && totp.Enabled() {
+		valid, err = models.UseRecoveryCode(c.Request.Context(), h.db, challenge.UserID, hashRecoveryCode(req.RecoveryCode))
+	} else {
+		valid, err = h.verifyTOTP(c.Request.Context(), totp, req.Code)
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке кода"})
+		return
+	}
+
+	if !valid {
+		if err := challenge.RecordFailure(c.Request.Context(), h.db); err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке кода"})
+			return
+		}
+		h.recordSecondFactorFailure(c.Request.Context(), challenge.UserID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный код подтверждения"})
+		return
+	}
+	h.resetSecondFactorFailures(c.Request.Context(), challenge.UserID)
+
+	completed, err := challenge.Complete(c.Request.Context(), h.db)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке кода"})
+		return
+	}
+	if !completed {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Сессия входа недействительна. Войдите заново"})
+		return
+	}
+
+	var recoveryCodes []string
+	if !totp.Enabled() {
+		var hashes []string
+		recoveryCodes, hashes, err = generateRecoveryCodes()
+		if err == nil {
+			err = models.EnableTOTP(c.Request.Context(), h.db, challenge.UserID, hashes)
+		}
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при подключении 2FA"})
+			return
+		}
+	}
+
+	var user models.User
+	if err := user.GetByID(c.Request.Context(), h.db, challenge.UserID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	response, err := h.issueTokens(c.Request.Context(), user)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при генерации токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorLoginResponse{LoginResponse: *response, RecoveryCodes: recoveryCodes})
+}
+
+// EnrollWithChallenge выдает секрет аутентификатора пользователю, для роли
+// которого 2FA обязательна, но еще не подключена. Токенов до подтверждения
+// кода такой пользователь не получает.
+func (h *AuthHandler) EnrollWithChallenge(c *gin.Context) {
+	var req models.TwoFactorEnrollChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, ok := h.loadChallenge(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+
+	h.startEnrollment(c, challenge.UserID)
+}
+
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	h.startEnrollment(c, c.GetInt("user_id"))
+}
+
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	totp, ok := h.loadTOTP(c, c.GetInt("user_id"))
+	if !ok {
+		return
+	}
+	if totp.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA уже подключена"})
+		return
+	}
+
+	if !h.checkTOTP(c, totp, req.Code) {
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = models.EnableTOTP(c.Request.Context(), h.db, totp.UserID, hashes)
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при подключении 2FA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := user.GetByID(c.Request.Context(), h.db, c.GetInt("user_id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	required, err := models.TwoFactorRequired(c.Request.Context(), h.db, user.RoleID)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отключении 2FA"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Для вашей роли 2FA обязательна"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный пароль"})
+		return
+	}
+
+	totp, ok := h.loadTOTP(c, user.ID)
+	if !ok {
+		return
+	}
+	if !h.checkTOTP(c, totp, req.Code) {
+		return
+	}
+
+	if err := models.DisableTOTP(c.Request.Context(), h.db, user.ID); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при отключении 2FA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA отключена"})
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	totp, ok := h.loadTOTP(c, c.GetInt("user_id"))
+	if !ok {
+		return
+	}
+	if !totp.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA не подключена"})
+		return
+	}
+	if !h.checkTOTP(c, totp, req.Code) {
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = models.ReplaceRecoveryCodes(c.Request.Context(), h.db, totp.UserID, hashes)
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при выпуске кодов восстановления"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// loginChallenge решает, нужен ли второй шаг входа. Возвращает nil, если
+// 2FA не подключена и не обязательна для роли пользователя.
+func (h *AuthHandler) loginChallenge(ctx context.Context, user models.User) (*models.LoginChallengeResponse, error) {
+	enabled := false
+	totp, err := models.GetUserTOTP(ctx, h.db, user.ID)
+	switch {
+	case err == nil:
+		enabled = totp.Enabled()
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	required, err := models.TwoFactorRequired(ctx, h.db, user.RoleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !enabled && !required {
+		return nil, nil
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := models.LoginChallenge{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(h.loginChallengeTTL),
+	}
+	if err := challenge.Create(ctx, h.db); err != nil {
+		return nil, err
+	}
+
+	return &models.LoginChallengeResponse{
+		TwoFactorRequired:  true,
+		EnrollmentRequired: !enabled,
+		ChallengeToken:     token,
+		ExpiresIn:          int(h.loginChallengeTTL.Seconds()),
+	}, nil
+}
+
+func (h *AuthHandler) startEnrollment(c *gin.Context, userID int) {
+	var user models.User
+	if err := user.GetByID(c.Request.Context(), h.db, userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при подключении 2FA"})
+		return
+	}
+
+	started, err := models.StartTOTPEnrollment(c.Request.Context(), h.db, user.ID, secret)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при подключении 2FA"})
+		return
+	}
+	if !started {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA уже подключена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorEnrollResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(h.totpIssuer, user.Email, secret),
+	})
+}
+
+func (h *AuthHandler) loadChallenge(c *gin.Context, token string) (*models.LoginChallenge, bool) {
+	challenge, err := models.GetLoginChallenge(c.Request.Context(), h.db, utils.HashToken(token))
+	if errors.Is(err, models.ErrChallengeInvalid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Сессия входа недействительна. Войдите заново"})
+		return nil, false
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке сессии входа"})
+		return nil, false
+	}
+	return challenge, true
+}
+
+func (h *AuthHandler) loadTOTP(c *gin.Context, userID int) (*models.UserTOTP, bool) {
+	totp, err := models.GetUserTOTP(c.Request.Context(), h.db, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Сначала получите секрет через /auth/2fa/setup"})
+		return nil, false
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении настроек 2FA"})
+		return nil, false
+	}
+	return totp, true
+}
+
+func (h *AuthHandler) checkTOTP(c *gin.Context, totp *models.UserTOTP, code string) bool {
+	if h.secondFactorLocked(c, totp.UserID) {
+		return false
+	}
+
+	valid, err := h.verifyTOTP(c.Request.Context(), totp, code)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке кода"})
+		return false
+	}
+	if !valid {
+		h.recordSecondFactorFailure(c.Request.Context(), totp.UserID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный код подтверждения"})
+		return false
+	}
+	h.resetSecondFactorFailures(c.Request.Context(), totp.UserID)
+	return true
+}
+
+// verifyTOTP проверяет код и не дает использовать его повторно.
+func (h *AuthHandler) verifyTOTP(ctx context.Context, totp *models.UserTOTP, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(totp.Secret, code, time.Now(), totp.LastUsedStep)
+	if !ok {
+		return false, nil
+	}
+	return models.UseTOTPStep(ctx, h.db, totp.UserID, step)
+}
+
+// secondFactorKey - ключ login_throttle для неверных кодов 2FA. Он отделен от
+// ключа аккаунта, который сбрасывается после верного пароля: иначе каждый
+// новый вход давал бы еще MaxChallengeAttempts попыток подобрать код.
+func secondFactorKey(userID int) string {
+	return "2fa:" + strconv.Itoa(userID)
+}
+
+// secondFactorLocked отвечает 429, если подбор кодов пользователя заблокирован.
+func (h *AuthHandler) secondFactorLocked(c *gin.Context, userID int) bool {
+	lockedUntil, err := models.LoginLockedUntil(c.Request.Context(), h.db, secondFactorKey(userID))
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при проверке блокировки входа"})
+		return true
+	}
+	if !lockedUntil.IsZero() {
+		respondLocked(c, lockedUntil)
+		return true
+	}
+	return false
+}
+
+func (h *AuthHandler) recordSecondFactorFailure(ctx context.Context, userID int) {
+	metrics.LoginFailures.WithLabelValues(metrics.LoginInvalidCode).Inc()
+
+	key := secondFactorKey(userID)
+	if err := models.RecordLoginFailure(ctx, h.db, key, h.loginMaxAttempts, h.loginWindow, h.loginLockout); err != nil {
+		slog.ErrorContext(ctx, "Failed to record login failure", "key", key, "error", err)
+	}
+}
+
+func (h *AuthHandler) resetSecondFactorFailures(ctx context.Context, userID int) {
+	key := secondFactorKey(userID)
+	if err := models.ResetLoginFailures(ctx, h.db, key); err != nil {
+		slog.ErrorContext(ctx, "Failed to reset login failures", "key", key, "error", err)
+	}
+}
+
+// generateRecoveryCodes возвращает коды для показа пользователю и их хеши
+// для хранения.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.RandomToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return utils.HashToken(normalized)
+}
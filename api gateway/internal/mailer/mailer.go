@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mailer доставляет служебные письма (сброс пароля и т.п.).
+type Mailer interface {
+	Send(to, subject, text string) error
+}
+
+// New выбирает реализацию по конфигурации: SMTP, если задан адрес сервера,
+// иначе письма складываются в каталог dir.
+func New(smtpAddr, from, dir string) Mailer {
+	if smtpAddr != "" {
+		return NewSMTPMailer(smtpAddr, from)
+	}
+	return NewFileMailer(dir, from)
+}
+
+// SMTPMailer отправляет письма через SMTP без авторизации. Для локальной
+// разработки подойдет MailHog на localhost:1025.
+type SMTPMailer struct {
+	addr string
+	from string
+}
+
+func NewSMTPMailer(addr, from string) *SMTPMailer {
+	return &SMTPMailer{addr: addr, from: from}
+}
+
+func (m *SMTPMailer) Send(to, subject, text string) error {
+	if err := smtp.SendMail(m.addr, nil, m.from, []string{to}, buildMessage(m.from, to, subject, text)); err != nil {
+		return fmt.Errorf("ошибка отправки email: %v", err)
+	}
+	return nil
+}
+
+// FileMailer сохраняет каждое письмо в отдельный .eml файл. Используется
+// в разработке и тестах вместо настоящей почты.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(to, subject, text string) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(to))
+	return os.WriteFile(filepath.Join(m.dir, name), buildMessage(m.from, to, subject, text), 0o600)
+}
+
+func buildMessage(from, to, subject, text string) []byte {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", from)
+	fmt.Fprintf(&body, "To: %s\r\n", to)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(text)
+	body.WriteString("\r\n")
+	return []byte(body.String())
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RouteKey - ключ контекста gin, в который прокси кладет шаблон маршрута
+// из таблицы маршрутов.
+const RouteKey = "route_pattern"
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Число обработанных HTTP запросов.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Время обработки HTTP запросов.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// LoginFailures - неудачные попытки входа по причинам.
+	LoginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_login_failures_total",
+		Help: "Число неудачных попыток входа.",
+	}, []string{"reason"})
+
+	// UpstreamRequests - попытки запросов к экземплярам сервисов, включая
+	// повторы, по результату: ok, error, canceled, circuit_open,
+	// no_healthy_instances.
+	UpstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_requests_total",
+		Help: "Число попыток запросов к сервисам.",
+	}, []string{"service", "outcome"})
+)
+
+// Причины неудачного входа для LoginFailures.
+const (
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginLocked             = "locked"
+	LoginInvalidCode        = "invalid_code"
+)
+
+// Handler отдает метрики в формате Prometheus. Он подключается к отдельному
+// listener, а не к публичному роутеру.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware считает запросы и время их обработки. Для проксируемых
+// запросов метка route - шаблон из таблицы маршрутов, для остальных -
+// маршрут gin.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.GetString(RouteKey)
+		if route == "" {
+			route = c.FullPath()
+		}
+		if route == "" {
+			route = "unmatched"
+		}
+
+		labels := prometheus.Labels{
+			"route":  route,
+			"method": c.Request.Method,
+			"status": strconv.Itoa(c.Writer.Status()),
+		}
+		requestsTotal.With(labels).Inc()
+		requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDB публикует статистику пула соединений с базой.
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "gateway"))
+}
+
+// RegisterGauge публикует значение, которое вычисляется при каждом сборе метрик.
+func RegisterGauge(name, help string, labels prometheus.Labels, value func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help, ConstLabels: labels}, value)
+}
@@ -1,15 +1,24 @@
 package middleware
 
 import (
+	"api-gateway/internal/models"
 	"api-gateway/internal/utils"
+	"common/logging"
+	"database/sql"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+func AuthMiddleware(keys *utils.KeySet, db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, db, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -25,15 +34,97 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		}
 
 		token := parts[1]
-		claims, err := utils.ValidateJWT(token, jwtSecret)
+		claims, err := utils.ValidateJWT(token, keys)
+		if err == nil && (claims.IssuedAt == nil || claims.ExpiresAt == nil) {
+			err = errors.New("token without iat/exp")
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		revoked, err := models.IsAccessTokenRevoked(c.Request.Context(), db, claims.ID, claims.UserID, claims.IssuedAt.Time)
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки токена"})
+			c.Abort()
+			return
+		}
+
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("role_id", claims.RoleID)
+		c.Set("permissions", claims.Permissions)
+		c.Set("token_id", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", claims.UserID))
 		c.Next()
 	}
 }
+
+// APIKeyScopeKey - ключ контекста gin с областью API-ключа, которую требует
+// маршрут. Его кладет прокси из таблицы маршрутов; если область не задана,
+// маршрут по API-ключу недоступен.
+const APIKeyScopeKey = "api_key_scope"
+
+// authenticateAPIKey - альтернатива Bearer JWT для машинных клиентов.
+// Права берутся из текущей роли владельца ключа, а области действия ключа
+// дополнительно ограничивают доступные маршруты. Ключ владельца, которому
+// 2FA обязательна, но не подключена, не действует: иначе ключ обходил бы 2FA.
+func authenticateAPIKey(c *gin.Context, db *sql.DB, key string) {
+	apiKey, roleID, err := models.AuthenticateAPIKey(c.Request.Context(), db, utils.HashToken(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки API-ключа"})
+		c.Abort()
+		return
+	}
+
+	scope := c.GetString(APIKeyScopeKey)
+	if scope == "" || !apiKey.HasScope(scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API-ключ не дает доступа к этому маршруту"})
+		c.Abort()
+		return
+	}
+
+	permissions, err := models.GetRolePermissions(c.Request.Context(), db, roleID)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки API-ключа"})
+		c.Abort()
+		return
+	}
+
+	if len(permissions) > 0 {
+		enabled, err := models.TwoFactorEnabled(c.Request.Context(), db, apiKey.UserID)
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки API-ключа"})
+			c.Abort()
+			return
+		}
+		if !enabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Для роли владельца ключа обязательна 2FA"})
+			c.Abort()
+			return
+		}
+	}
+
+	c.Set("user_id", apiKey.UserID)
+	c.Set("role_id", roleID)
+	c.Set("permissions", permissions)
+	c.Set("api_key_id", apiKey.ID)
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", apiKey.UserID, "api_key_id", apiKey.ID))
+	c.Next()
+}
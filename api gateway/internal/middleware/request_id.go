@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"api-gateway/internal/utils"
+	"common/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey - ключ контекста gin с идентификатором запроса.
+	RequestIDKey = "request_id"
+	// ProxiedKey ставит прокси: ответ сервиса передается клиенту как есть.
+	ProxiedKey = "proxied"
+
+	maxRequestIDLength = 128
+)
+
+// RequestID принимает идентификатор запроса от клиента или выдает новый.
+// Идентификатор возвращается в заголовке ответа, передается сервисам и
+// дописывается в JSON ответы шлюза с ошибкой.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "request_id", id))
+		c.Request.Header.Set(RequestIDHeader, id)
+		c.Header(RequestIDHeader, id)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", id))
+
+		writer := &errorBodyWriter{ResponseWriter: c.Writer, c: c, requestID: id}
+		c.Writer = writer
+		c.Next()
+		writer.flush()
+	}
+}
+
+// validRequestID: чужой идентификатор попадает в логи и заголовки, поэтому
+// допускаются только короткие строки из безопасных символов.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("-_.:", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id, err := utils.RandomToken(16)
+	if err != nil {
+		return "unknown"
+	}
+	return id
+}
+
+// errorBodyWriter придерживает JSON ответ шлюза с ошибкой, чтобы дописать
+// в него request_id. Остальные ответы, включая ответы сервисов через прокси,
+// пишутся напрямую.
+type errorBodyWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	requestID string
+	buf       *bytes.Buffer
+}
+
+func (w *errorBodyWriter) Write(data []byte) (int, error) {
+	if w.buf == nil && w.shouldBuffer() {
+		w.buf = new(bytes.Buffer)
+	}
+	if w.buf != nil {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorBodyWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *errorBodyWriter) shouldBuffer() bool {
+	return !w.ResponseWriter.Written() &&
+		w.Status() >= 400 &&
+		!w.c.GetBool(ProxiedKey) &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+func (w *errorBodyWriter) flush() {
+	if w.buf == nil {
+		return
+	}
+
+	body := w.buf.Bytes()
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) == nil && fields != nil {
+		fields["request_id"], _ = json.Marshal(w.requestID)
+		if rewritten, err := json.Marshal(fields); err == nil {
+			body = rewritten
+		}
+	}
+
+	w.buf = nil
+	w.ResponseWriter.Write(body)
+}
@@ -6,21 +6,25 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func PoliceOnlyMiddleware() gin.HandlerFunc {
+// RequirePermission пропускает запрос, только если в токене есть указанное право.
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleID, exists := c.Get("role_id")
+		value, exists := c.Get("permissions")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Role not found in context"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Permissions not found in context"})
 			c.Abort()
 			return
 		}
 
-		if roleID != 2 {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Доступ запрещен. Требуются права полиции"})
-			c.Abort()
-			return
+		permissions, _ := value.([]string)
+		for _, p := range permissions {
+			if p == permission {
+				c.Next()
+				return
+			}
 		}
 
-		c.Next()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Доступ запрещен. Требуется право " + permission})
+		c.Abort()
 	}
 }
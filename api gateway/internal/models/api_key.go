@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Области действия API-ключей. Область маршрута /api задается полем scope
+// в таблице маршрутов; маршруты без области, /auth и /admin API-ключам
+// недоступны.
+const (
+	ScopeDrones        = "drones"
+	ScopeOrg           = "org"
+	ScopeRequests      = "requests"
+	ScopeNotifications = "notifications"
+	ScopeWebhooks      = "webhooks"
+	ScopeMap           = "map"
+)
+
+var APIKeyScopes = []string{ScopeDrones, ScopeOrg, ScopeRequests, ScopeNotifications, ScopeWebhooks, ScopeMap}
+
+// APIKeyPrefix отличает API-ключи от других токенов, например в логах и
+// сканерах утечек.
+const APIKeyPrefix = "dk_"
+
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+type CreateAPIKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
+
+type RevokeAPIKeyRequest struct {
+	ID int `json:"id" binding:"required"`
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) Create(ctx context.Context, db *sql.DB) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return db.QueryRowContext(ctx, query, k.UserID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), k.ExpiresAt).
+		Scan(&k.ID, &k.CreatedAt)
+}
+
+func GetUserAPIKeys(ctx context.Context, db *sql.DB, userID int) ([]APIKey, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM api_keys WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes),
+			&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey отзывает ключ пользователя. Возвращает false, если ключ не
+// найден или уже отозван.
+func RevokeAPIKey(ctx context.Context, db *sql.DB, userID, keyID int) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, keyID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// AuthenticateAPIKey находит действующий ключ по хешу и возвращает его
+// вместе с текущей ролью владельца. Время последнего использования
+// обновляется не чаще раза в минуту.
+func AuthenticateAPIKey(ctx context.Context, db *sql.DB, keyHash string) (*APIKey, int, error) {
+	query := `
+		SELECT k.id, k.user_id, k.name, k.prefix, k.scopes, u.role_id
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+		  AND (k.expires_at IS NULL OR k.expires_at > CURRENT_TIMESTAMP)`
+
+	var k APIKey
+	var roleID int
+	if err := db.QueryRowContext(ctx, query, keyHash).Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &roleID); err != nil {
+		return nil, 0, err
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')`, k.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &k, roleID, nil
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// LoginLockedUntil возвращает самое позднее время окончания блокировки среди
+// ключей (IP, аккаунт). Нулевое время означает, что вход разрешен.
+func LoginLockedUntil(ctx context.Context, db *sql.DB, keys ...string) (time.Time, error) {
+	var lockedUntil sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		SELECT MAX(locked_until) FROM login_throttle
+		WHERE key = ANY($1) AND locked_until > CURRENT_TIMESTAMP`, pq.Array(keys)).Scan(&lockedUntil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return lockedUntil.Time, nil
+}
+
+// RecordLoginFailure учитывает неудачную попытку входа. Счетчик работает в
+// фиксированном окне window; при достижении maxAttempts ключ блокируется на
+// lockout, а счетчик обнуляется.
+func RecordLoginFailure(ctx context.Context, db *sql.DB, key string, maxAttempts int, window, lockout time.Duration) error {
+	var failures int
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO login_throttle (key, failures, window_start)
+		VALUES ($1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttle.window_start < CURRENT_TIMESTAMP - make_interval(secs => $2)
+			                THEN 1 ELSE login_throttle.failures + 1 END,
+			window_start = CASE WHEN login_throttle.window_start < CURRENT_TIMESTAMP - make_interval(secs => $2)
+			                    THEN CURRENT_TIMESTAMP ELSE login_throttle.window_start END
+		RETURNING failures`, key, window.Seconds()).Scan(&failures)
+	if err != nil {
+		return err
+	}
+
+	if failures < maxAttempts {
+		return nil
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE login_throttle
+		SET failures = 0, window_start = CURRENT_TIMESTAMP,
+		    locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE key = $1`, key, lockout.Seconds())
+	return err
+}
+
+func ResetLoginFailures(ctx context.Context, db *sql.DB, key string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM login_throttle WHERE key = $1`, key)
+	return err
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+const (
+	PermRequestsReview   = "requests:review"
+	PermZonesWrite       = "zones:write"
+	PermDronesForceStop  = "drones:force_stop"
+	PermAirspaceLockdown = "airspace:lockdown"
+	PermRolesManage      = "roles:manage"
+)
+
+type Permission struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RoleWithPermissions struct {
+	UserRole
+	Permissions []string `json:"permissions"`
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type SetRolePermissionsRequest struct {
+	RoleID      int      `json:"role_id" binding:"required"`
+	Permissions []string `json:"permissions"`
+}
+
+func GetRolePermissions(ctx context.Context, db *sql.DB, roleID int) ([]string, error) {
+	query := `
+		SELECT p.name
+		FROM role_permissions rp
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE rp.role_id = $1
+		ORDER BY p.name`
+
+	rows, err := db.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+
+	return permissions, rows.Err()
+}
+
+func GetPermissions(ctx context.Context, db *sql.DB) ([]Permission, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, name, COALESCE(description, '') FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+
+	return permissions, rows.Err()
+}
+
+func GetRolesWithPermissions(ctx context.Context, db *sql.DB) ([]RoleWithPermissions, error) {
+	query := `
+		SELECT r.id, r.name, COALESCE(r.description, ''),
+		       COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+		FROM user_roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id
+		ORDER BY r.id`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []RoleWithPermissions{}
+	for rows.Next() {
+		var r RoleWithPermissions
+		if err := rows.Scan(&r.ID, &r.Name, &r.Description, pq.Array(&r.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *UserRole) Create(ctx context.Context, db *sql.DB) error {
+	return db.QueryRowContext(ctx, `INSERT INTO user_roles (name, description) VALUES ($1, $2) RETURNING id`,
+		r.Name, r.Description).Scan(&r.ID)
+}
+
+// SetRolePermissions заменяет набор прав роли. Токены пользователей с этой
+// ролью отзываются, так как содержат старый набор прав. Если у роли остались
+// права, для нее обязательна 2FA: пользователи без нее теряют и refresh-токены
+// и должны войти заново через подключение аутентификатора.
+func SetRolePermissions(ctx context.Context, db *sql.DB, roleID int, permissions []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role_id = $1`, roleID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, id FROM permissions WHERE name = ANY($2)`, roleID, pq.Array(permissions))
+	if err != nil {
+		return err
+	}
+
+	if granted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if granted > 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			WHERE revoked_at IS NULL AND user_id IN (
+				SELECT u.id FROM users u
+				LEFT JOIN user_totp t ON t.user_id = u.id
+				WHERE u.role_id = $1 AND t.enabled_at IS NULL
+			)`, roleID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET sessions_revoked_at = $1 WHERE role_id = $2`, sessionsRevokedAt(), roleID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnknownPermissions возвращает имена из списка, которых нет в таблице permissions.
+func UnknownPermissions(ctx context.Context, db *sql.DB, names []string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT n FROM unnest($1::text[]) AS n
+		WHERE NOT EXISTS (SELECT 1 FROM permissions WHERE name = n)`, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var unknown []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		unknown = append(unknown, name)
+	}
+
+	return unknown, rows.Err()
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	RoleChangeSourceAdmin      = "admin"
+	RoleChangeSourceInvitation = "invitation"
+	RoleChangeSourceBootstrap  = "bootstrap"
+)
+
+var ErrInvitationInvalid = errors.New("invitation not found, expired or already used")
+
+type RoleChange struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	OldRoleID *int      `json:"old_role_id"`
+	NewRoleID int       `json:"new_role_id"`
+	ChangedBy *int      `json:"changed_by"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RoleInvitation struct {
+	ID        int        `json:"id"`
+	CodeHash  string     `json:"-"`
+	RoleID    int        `json:"role_id"`
+	CreatedBy int        `json:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedBy    *int       `json:"used_by"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ChangeRoleRequest struct {
+	UserID int    `json:"user_id" binding:"required"`
+	RoleID int    `json:"role_id" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+type CreateInvitationRequest struct {
+	RoleID         int `json:"role_id" binding:"required"`
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+type CreateInvitationResponse struct {
+	Code       string         `json:"code"`
+	Invitation RoleInvitation `json:"invitation"`
+}
+
+func (r *UserRole) GetByID(ctx context.Context, db *sql.DB, id int) error {
+	return db.QueryRowContext(ctx, `SELECT id, name, COALESCE(description, '') FROM user_roles WHERE id = $1`, id).
+		Scan(&r.ID, &r.Name, &r.Description)
+}
+
+func (r *UserRole) GetByName(ctx context.Context, db *sql.DB, name string) error {
+	return db.QueryRowContext(ctx, `SELECT id, name, COALESCE(description, '') FROM user_roles WHERE name = $1`, name).
+		Scan(&r.ID, &r.Name, &r.Description)
+}
+
+// ChangeUserRole меняет роль пользователя и записывает изменение в role_changes.
+// changedBy равен nil для изменений, сделанных системой. Выданные ранее токены
+// пользователя перестают действовать, так как содержат старую роль.
+func ChangeUserRole(ctx context.Context, db *sql.DB, userID, newRoleID int, changedBy *int, source, reason string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := changeUserRoleTx(ctx, tx, userID, newRoleID, changedBy, source, reason); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return RevokeAllSessions(ctx, db, userID)
+}
+
+func changeUserRoleTx(ctx context.Context, tx *sql.Tx, userID, newRoleID int, changedBy *int, source, reason string) error {
+	var oldRoleID sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `SELECT role_id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&oldRoleID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET role_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, newRoleID, userID); err != nil {
+		return err
+	}
+
+	var oldRole *int
+	if oldRoleID.Valid {
+		v := int(oldRoleID.Int64)
+		oldRole = &v
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO role_changes (user_id, old_role_id, new_role_id, changed_by, source, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		userID, oldRole, newRoleID, changedBy, source, reason)
+	return err
+}
+
+func GetRoleChanges(ctx context.Context, db *sql.DB, userID int) ([]RoleChange, error) {
+	query := `
+		SELECT id, user_id, old_role_id, new_role_id, changed_by, source, reason, created_at
+		FROM role_changes
+		WHERE $1 = 0 OR user_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []RoleChange{}
+	for rows.Next() {
+		var rc RoleChange
+		if err := rows.Scan(&rc.ID, &rc.UserID, &rc.OldRoleID, &rc.NewRoleID, &rc.ChangedBy,
+			&rc.Source, &rc.Reason, &rc.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, rc)
+	}
+
+	return changes, rows.Err()
+}
+
+func (i *RoleInvitation) Create(ctx context.Context, db *sql.DB) error {
+	query := `
+		INSERT INTO role_invitations (code_hash, role_id, created_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	return db.QueryRowContext(ctx, query, i.CodeHash, i.RoleID, i.CreatedBy, i.ExpiresAt).Scan(&i.ID, &i.CreatedAt)
+}
+
+// IsInvitationValid проверяет, что приглашение существует, не истекло и не использовано.
+func IsInvitationValid(ctx context.Context, db *sql.DB, codeHash string) (bool, error) {
+	var valid bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM role_invitations
+			WHERE code_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		)`, codeHash).Scan(&valid)
+	return valid, err
+}
+
+// redeemInvitationTx погашает приглашение и выдает пользователю его роль.
+func redeemInvitationTx(ctx context.Context, tx *sql.Tx, codeHash string, userID int) error {
+	var invitationID, roleID, createdBy int
+	err := tx.QueryRowContext(ctx, `
+		UPDATE role_invitations SET used_by = $2, used_at = CURRENT_TIMESTAMP
+		WHERE code_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING id, role_id, created_by`, codeHash, userID).Scan(&invitationID, &roleID, &createdBy)
+	if err == sql.ErrNoRows {
+		return ErrInvitationInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	return changeUserRoleTx(ctx, tx, userID, roleID, &createdBy, RoleChangeSourceInvitation, "")
+}
+
+// BootstrapAdmin выдает роль администратора пользователю с указанным email,
+// только пока в системе нет ни одного администратора. Email при регистрации
+// не подтверждается, поэтому после назначения первого администратора
+// ADMIN_EMAIL ничего не меняет: ни захваченный адрес, ни снятый вручную
+// администратор не получат роль при следующем запуске.
+func BootstrapAdmin(ctx context.Context, db *sql.DB, email string) error {
+	var adminRole UserRole
+	if err := adminRole.GetByName(ctx, db, RoleAdmin); err != nil {
+		return err
+	}
+
+	var hasAdmin bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE role_id = $1)`, adminRole.ID).Scan(&hasAdmin)
+	if err != nil {
+		return err
+	}
+	if hasAdmin {
+		return nil
+	}
+
+	var user User
+	if err := user.GetByEmail(ctx, db, email); err != nil {
+		return err
+	}
+
+	return ChangeUserRole(ctx, db, user.ID, adminRole.ID, nil, RoleChangeSourceBootstrap, "ADMIN_EMAIL")
+}
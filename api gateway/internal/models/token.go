@@ -0,0 +1,103 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"api-gateway/internal/utils"
+)
+
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// All завершает все сессии пользователя, а не только текущую.
+	All bool `json:"all"`
+}
+
+func (t *RefreshToken) Create(ctx context.Context, db *sql.DB) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	return db.QueryRowContext(ctx, query, t.UserID, t.TokenHash, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+}
+
+func (t *RefreshToken) GetByHash(ctx context.Context, db *sql.DB, hash string) error {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1`
+
+	return db.QueryRowContext(ctx, query, hash).Scan(
+		&t.ID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.RevokedAt, &t.CreatedAt,
+	)
+}
+
+// Revoke помечает токен отозванным. Возвращает false, если токен уже был отозван.
+func (t *RefreshToken) Revoke(ctx context.Context, db *sql.DB) (bool, error) {
+	result, err := db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`, t.ID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// RevokeAllSessions отзывает все refresh-токены пользователя и делает
+// недействительными все выданные ранее access-токены.
+func RevokeAllSessions(ctx context.Context, db *sql.DB, userID int) error {
+	if _, err := db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, `UPDATE users SET sessions_revoked_at = $1 WHERE id = $2`, sessionsRevokedAt(), userID)
+	return err
+}
+
+// sessionsRevokedAt - момент отзыва сессий по часам шлюза, которыми
+// подписывается iat: часы базы могут расходиться с ними на миллисекунды.
+func sessionsRevokedAt() time.Time {
+	return time.Now().Truncate(utils.TokenTimePrecision)
+}
+
+func RevokeAccessToken(ctx context.Context, db *sql.DB, jti string, expiresAt time.Time) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	return err
+}
+
+// IsAccessTokenRevoked проверяет, отозван ли токен явно (logout) или
+// выдан до завершения всех сессий пользователя. iat и sessions_revoked_at
+// хранятся с точностью utils.TokenTimePrecision; токен, выданный в тот же
+// момент, что и отзыв, считается отозванным.
+func IsAccessTokenRevoked(ctx context.Context, db *sql.DB, jti string, userID int, issuedAt time.Time) (bool, error) {
+	query := `
+		SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		    OR COALESCE((SELECT sessions_revoked_at >= $3 FROM users WHERE id = $2), FALSE)`
+
+	var revoked bool
+	err := db.QueryRowContext(ctx, query, jti, userID, issuedAt).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxChallengeAttempts - сколько неверных кодов можно ввести по одному
+// challenge-токену, прежде чем придется заново вводить пароль.
+const MaxChallengeAttempts = 5
+
+var ErrChallengeInvalid = errors.New("login challenge not found, expired or exhausted")
+
+type UserTOTP struct {
+	UserID       int
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+type LoginChallenge struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+type LoginChallengeResponse struct {
+	TwoFactorRequired bool `json:"two_factor_required"`
+	// EnrollmentRequired означает, что для роли пользователя 2FA обязательна,
+	// но аутентификатор еще не подключен.
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	ChallengeToken     string `json:"challenge_token"`
+	ExpiresIn          int    `json:"expires_in"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type TwoFactorEnrollChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TwoFactorLoginResponse - результат второго шага входа. RecoveryCodes
+// заполняется, только если при входе был завершен процесс подключения 2FA.
+type TwoFactorLoginResponse struct {
+	LoginResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+func (t *UserTOTP) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TwoFactorRequired: 2FA обязательна для любой роли с правами сверх
+// обычного пользователя (полиция, администраторы и т.п.).
+func TwoFactorRequired(ctx context.Context, db *sql.DB, roleID int) (bool, error) {
+	permissions, err := GetRolePermissions(ctx, db, roleID)
+	if err != nil {
+		return false, err
+	}
+	return len(permissions) > 0, nil
+}
+
+// MissingTwoFactor сообщает, что для роли пользователя 2FA обязательна, но
+// аутентификатор не подключен. Такой пользователь получает доступ только
+// через вход с паролем и подключение 2FA.
+func MissingTwoFactor(ctx context.Context, db *sql.DB, userID, roleID int) (bool, error) {
+	required, err := TwoFactorRequired(ctx, db, roleID)
+	if err != nil || !required {
+		return false, err
+	}
+
+	enabled, err := TwoFactorEnabled(ctx, db, userID)
+	return !enabled, err
+}
+
+// TwoFactorEnabled сообщает, подтвердил ли пользователь подключение аутентификатора.
+func TwoFactorEnabled(ctx context.Context, db *sql.DB, userID int) (bool, error) {
+	var enabled bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = $1 AND enabled_at IS NOT NULL)`, userID).
+		Scan(&enabled)
+	return enabled, err
+}
+
+// GetUserTOTP возвращает настройки аутентификатора. sql.ErrNoRows означает,
+// что пользователь 2FA не подключал.
+func GetUserTOTP(ctx context.Context, db *sql.DB, userID int) (*UserTOTP, error) {
+	var t UserTOTP
+	err := db.QueryRowContext(ctx, `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_totp WHERE user_id = $1`, userID).
+		Scan(&t.UserID, &t.Secret, &t.EnabledAt, &t.LastUsedStep, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// StartTOTPEnrollment сохраняет новый секрет, пока 2FA еще не подтверждена.
+// Подключенный аутентификатор этим методом не заменяется.
+func StartTOTPEnrollment(ctx context.Context, db *sql.DB, userID int, secret string) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0,
+		                                    created_at = CURRENT_TIMESTAMP
+		WHERE user_totp.enabled_at IS NULL`, userID, secret)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// UseTOTPStep фиксирует использованный шаг. Возвращает false, если код этого
+// или более позднего шага уже принимался, то есть код перехвачен повторно.
+func UseTOTPStep(ctx context.Context, db *sql.DB, userID int, step int64) (bool, error) {
+	result, err := db.ExecContext(ctx, `UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// EnableTOTP подтверждает подключение аутентификатора и выпускает новые
+// коды восстановления.
+func EnableTOTP(ctx context.Context, db *sql.DB, userID int, recoveryCodeHashes []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE user_totp SET enabled_at = CURRENT_TIMESTAMP WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodesTx(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func DisableTOTP(ctx context.Context, db *sql.DB, userID int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ReplaceRecoveryCodes(ctx context.Context, db *sql.DB, userID int, hashes []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodesTx(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodesTx(ctx context.Context, tx *sql.Tx, userID int, hashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO totp_recovery_codes (user_id, code_hash)
+		SELECT $1, h FROM unnest($2::text[]) AS h`, userID, pq.Array(hashes))
+	return err
+}
+
+// UseRecoveryCode гасит одноразовый код восстановления.
+func UseRecoveryCode(ctx context.Context, db *sql.DB, userID int, codeHash string) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE totp_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (ch *LoginChallenge) Create(ctx context.Context, db *sql.DB) error {
+	query := `
+		INSERT INTO login_challenges (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	return db.QueryRowContext(ctx, query, ch.UserID, ch.TokenHash, ch.ExpiresAt).Scan(&ch.ID)
+}
+
+// GetLoginChallenge возвращает действующий challenge по хешу токена.
+func GetLoginChallenge(ctx context.Context, db *sql.DB, tokenHash string) (*LoginChallenge, error) {
+	var ch LoginChallenge
+	err := db.QueryRowContext(ctx, `
+		SELECT id, user_id, token_hash, expires_at
+		FROM login_challenges
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP AND attempts < $2`,
+		tokenHash, MaxChallengeAttempts).Scan(&ch.ID, &ch.UserID, &ch.TokenHash, &ch.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrChallengeInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func (ch *LoginChallenge) RecordFailure(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `UPDATE login_challenges SET attempts = attempts + 1 WHERE id = $1`, ch.ID)
+	return err
+}
+
+// Complete гасит challenge. Возвращает false, если его уже использовал
+// параллельный запрос.
+func (ch *LoginChallenge) Complete(ctx context.Context, db *sql.DB) (bool, error) {
+	result, err := db.ExecContext(ctx, `UPDATE login_challenges SET used_at = CURRENT_TIMESTAMP WHERE id = $1 AND used_at IS NULL`, ch.ID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
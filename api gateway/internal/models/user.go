@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
@@ -18,6 +19,12 @@ type User struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+const (
+	RoleUser   = "user"
+	RolePolice = "police"
+	RoleAdmin  = "admin"
+)
+
 type UserRole struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
@@ -30,7 +37,9 @@ type RegisterRequest struct {
 	Address  string `json:"address"`
 	Phone    string `json:"phone"`
 	Password string `json:"password" binding:"required"`
-	RoleID   int    `json:"role_id"`
+	// InviteCode - необязательный код приглашения, выданный администратором
+	// для получения повышенной роли.
+	InviteCode string `json:"invite_code"`
 }
 
 type LoginRequest struct {
@@ -38,12 +47,27 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// UpdateProfileRequest заменяет редактируемые поля профиля. Email не
+// меняется, так как используется для входа и восстановления пароля.
+type UpdateProfileRequest struct {
+	FullName string `json:"full_name" binding:"required"`
+	Address  string `json:"address"`
+	Phone    string `json:"phone"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         User   `json:"user"`
 }
 
-func (u *User) GetByEmail(db *sql.DB, email string) error {
+func (u *User) GetByEmail(ctx context.Context, db *sql.DB, email string) error {
 	query := `
 		SELECT u.id, u.full_name, u.email, u.address, u.phone, u.password_hash, 
 		       u.role_id, ur.name, u.created_at, u.updated_at
@@ -51,24 +75,45 @@ func (u *User) GetByEmail(db *sql.DB, email string) error {
 		LEFT JOIN user_roles ur ON u.role_id = ur.id
 		WHERE u.email = $1`
 
-	return db.QueryRow(query, email).Scan(
+	return db.QueryRowContext(ctx, query, email).Scan(
 		&u.ID, &u.FullName, &u.Email, &u.Address, &u.Phone,
 		&u.PasswordHash, &u.RoleID, &u.RoleName, &u.CreatedAt, &u.UpdatedAt,
 	)
 }
 
-func (u *User) Create(db *sql.DB) error {
+// Create создает пользователя. Если задан inviteHash, приглашение погашается
+// в той же транзакции: при недействительном приглашении (ErrInvitationInvalid)
+// пользователь не создается.
+func (u *User) Create(ctx context.Context, db *sql.DB, inviteHash string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		INSERT INTO users (full_name, email, address, phone, password_hash, role_id)
 		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at`
 
-	return db.QueryRow(query,
+	err = tx.QueryRowContext(ctx, query,
 		u.FullName, u.Email, u.Address, u.Phone, u.PasswordHash, u.RoleID,
 	).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Повышенную роль можно получить только по приглашению администратора.
+	if inviteHash != "" {
+		if err := redeemInvitationTx(ctx, tx, inviteHash, u.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (u *User) GetByID(db *sql.DB, id int) error {
+func (u *User) GetByID(ctx context.Context, db *sql.DB, id int) error {
 	query := `
 		SELECT u.id, u.full_name, u.email, u.address, u.phone, u.password_hash, 
 		       u.role_id, ur.name, u.created_at, u.updated_at
@@ -76,8 +121,22 @@ func (u *User) GetByID(db *sql.DB, id int) error {
 		LEFT JOIN user_roles ur ON u.role_id = ur.id
 		WHERE u.id = $1`
 
-	return db.QueryRow(query, id).Scan(
+	return db.QueryRowContext(ctx, query, id).Scan(
 		&u.ID, &u.FullName, &u.Email, &u.Address, &u.Phone,
 		&u.PasswordHash, &u.RoleID, &u.RoleName, &u.CreatedAt, &u.UpdatedAt,
 	)
 }
+
+func (u *User) UpdateProfile(ctx context.Context, db *sql.DB) error {
+	query := `
+		UPDATE users SET full_name = $1, address = $2, phone = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+		RETURNING updated_at`
+
+	return db.QueryRowContext(ctx, query, u.FullName, u.Address, u.Phone, u.ID).Scan(&u.UpdatedAt)
+}
+
+func UpdatePassword(ctx context.Context, db *sql.DB, userID int, passwordHash string) error {
+	_, err := db.ExecContext(ctx, `UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, passwordHash, userID)
+	return err
+}
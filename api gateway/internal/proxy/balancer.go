@@ -0,0 +1,295 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoHealthyInstances = errors.New("no healthy instances")
+
+const (
+	BalanceRoundRobin       = "round-robin"
+	BalanceLeastConnections = "least-connections"
+)
+
+// instance - один экземпляр сервиса. Circuit breaker у каждого экземпляра
+// свой: отказ одного не должен отключать остальные.
+type instance struct {
+	url     *url.URL
+	breaker *CircuitBreaker
+
+	active   int64 // запросы, ответ на которые еще не дочитан
+	healthy  atomic.Bool
+	failures int // неудачные проверки подряд, меняет только checkHealth
+
+	// Результат последней проверки для /admin/status.
+	mu        sync.Mutex
+	lastCheck time.Time
+	lastError string
+	version   string
+}
+
+type InstanceStatus struct {
+	Healthy   bool      `json:"healthy"`
+	Breaker   string    `json:"breaker"`
+	Version   string    `json:"version,omitempty"`
+	LastCheck time.Time `json:"last_check"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func (inst *instance) status() InstanceStatus {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	return InstanceStatus{
+		Healthy:   inst.healthy.Load(),
+		Breaker:   inst.breaker.State(),
+		Version:   inst.version,
+		LastCheck: inst.lastCheck,
+		Error:     inst.lastError,
+	}
+}
+
+type balancer struct {
+	instances []*instance
+	strategy  string
+	next      uint64
+}
+
+func newBalancer(rawURLs []string, strategy string, breakerThreshold int, breakerCooldown time.Duration) (*balancer, error) {
+	switch strategy {
+	case BalanceRoundRobin, BalanceLeastConnections:
+	default:
+		return nil, errors.New("неизвестная стратегия балансировки " + strategy)
+	}
+	if len(rawURLs) == 0 {
+		return nil, errors.New("не указан ни один адрес")
+	}
+
+	b := &balancer{strategy: strategy}
+	for _, rawURL := range rawURLs {
+		target, err := url.Parse(rawURL)
+		if err != nil || target.Host == "" {
+			return nil, errors.New("неверный адрес " + rawURL)
+		}
+		// Путь к сервису подставляет ReverseProxy, а экземпляр выбирается уже
+		// в транспорте, поэтому пути у экземпляров должны совпадать.
+		if len(b.instances) > 0 && strings.TrimSuffix(target.Path, "/") != strings.TrimSuffix(b.instances[0].url.Path, "/") {
+			return nil, errors.New("у экземпляров различаются пути: " + rawURL)
+		}
+
+		inst := &instance{url: target, breaker: NewCircuitBreaker(breakerThreshold, breakerCooldown)}
+		inst.healthy.Store(true)
+		b.instances = append(b.instances, inst)
+	}
+	return b, nil
+}
+
+// pick выбирает экземпляр, пропуская исключенные и выведенные проверкой
+// здоровья. Запрос с ключом привязки всегда уходит на один и тот же
+// экземпляр (rendezvous hashing): при выходе экземпляра из строя на другие
+// переезжают только его ключи.
+func (b *balancer) pick(stickyKey string, exclude map[*instance]bool) (*instance, error) {
+	candidates := make([]*instance, 0, len(b.instances))
+	for _, inst := range b.instances {
+		if inst.healthy.Load() && !exclude[inst] {
+			candidates = append(candidates, inst)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoHealthyInstances
+	}
+
+	switch {
+	case stickyKey != "":
+		return pickByKey(candidates, stickyKey), nil
+	case b.strategy == BalanceLeastConnections:
+		best := candidates[0]
+		for _, inst := range candidates[1:] {
+			if atomic.LoadInt64(&inst.active) < atomic.LoadInt64(&best.active) {
+				best = inst
+			}
+		}
+		return best, nil
+	}
+
+	n := atomic.AddUint64(&b.next, 1)
+	return candidates[(n-1)%uint64(len(candidates))], nil
+}
+
+func pickByKey(candidates []*instance, key string) *instance {
+	var best *instance
+	var bestScore uint64
+	for _, inst := range candidates {
+		h := fnv.New64a()
+		io.WriteString(h, inst.url.Host)
+		io.WriteString(h, "/")
+		io.WriteString(h, key)
+		if score := mix64(h.Sum64()); best == nil || score > bestScore {
+			best, bestScore = inst, score
+		}
+	}
+	return best
+}
+
+// mix64 перемешивает биты хеша: у FNV ключи, отличающиеся последними
+// байтами (адреса на соседних портах), дают близкие старшие биты.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// checkHealth опрашивает все экземпляры. Экземпляр выводится из балансировки
+// после unhealthyAfter неудачных проверок подряд и возвращается после первой
+// успешной.
+func (b *balancer) checkHealth(client *http.Client, service, path string, unhealthyAfter int) {
+	var wg sync.WaitGroup
+	for _, inst := range b.instances {
+		wg.Add(1)
+		go func(inst *instance) {
+			defer wg.Done()
+
+			version, err := probe(client, inst.url.JoinPath(path).String())
+
+			inst.mu.Lock()
+			inst.lastCheck = time.Now()
+			// Текст ошибки раскрывает адрес экземпляра, он только в логе.
+			inst.lastError = ""
+			if err != nil {
+				inst.lastError = "error"
+			}
+			if version != "" {
+				inst.version = version
+			}
+			inst.mu.Unlock()
+
+			switch {
+			case err == nil:
+				inst.failures = 0
+				if !inst.healthy.Swap(true) {
+					slog.Info("Instance is healthy again", "service", service, "instance", inst.url.Host)
+				}
+			default:
+				inst.failures++
+				slog.Debug("Instance health check failed", "service", service, "instance", inst.url.Host, "error", err)
+				if inst.failures >= unhealthyAfter && inst.healthy.Swap(false) {
+					slog.Warn("Instance is unhealthy, removing from rotation", "service", service, "instance", inst.url.Host, "error", err)
+				}
+			}
+		}(inst)
+	}
+	wg.Wait()
+}
+
+// probe считает экземпляр живым, если он отвечает без ошибки 5xx, и
+// возвращает версию из ответа /readyz, если она там есть.
+func probe(client *http.Client, target string) (string, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var report struct {
+		Version string `json:"version"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&report)
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return report.Version, errors.New(resp.Status)
+	}
+	return report.Version, nil
+}
+
+type stickyContextKey struct{}
+
+func withStickyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, stickyContextKey{}, key)
+}
+
+func stickyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(stickyContextKey{}).(string)
+	return key
+}
+
+// stickyValue ищет поле field в параметрах запроса, а затем в JSON теле,
+// если оно буферизовано. Числа и строки дают одинаковый ключ: 5 и "5".
+func stickyValue(r *http.Request, field string) string {
+	if value := r.URL.Query().Get(field); value != "" {
+		return value
+	}
+	if r.GetBody == nil {
+		return ""
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	var fields map[string]json.RawMessage
+	if json.NewDecoder(body).Decode(&fields) != nil {
+		return ""
+	}
+	raw, ok := fields[field]
+	if !ok {
+		return ""
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// countingBody уменьшает счетчик активных запросов экземпляра, когда
+// ответ дочитан и закрыт - для потоковых ответов это намного позже
+// возврата из RoundTrip.
+type countingBody struct {
+	io.ReadCloser
+	inst *instance
+	once sync.Once
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { atomic.AddInt64(&b.inst.active, -1) })
+	return b.ReadCloser.Close()
+}
+
+// countingConn - countingBody для ответа 101 Switching Protocols. Тело
+// такого ответа - само соединение, и ReverseProxy пишет в него, поэтому
+// Write должен остаться доступен. Соединение освобождается при Close.
+type countingConn struct {
+	*countingBody
+	io.Writer
+}
+
+// newCountingBody оборачивает тело ответа, сохраняя io.Writer, если он есть.
+func newCountingBody(body io.ReadCloser, inst *instance) io.ReadCloser {
+	counting := &countingBody{ReadCloser: body, inst: inst}
+	if w, ok := body.(io.Writer); ok {
+		return &countingConn{countingBody: counting, Writer: w}
+	}
+	return counting
+}
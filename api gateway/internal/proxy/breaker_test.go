@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type breakerOp int
+
+const (
+	opAllow breakerOp = iota
+	opSuccess
+	opFailure
+	opCancel
+	// opCooldown сдвигает момент открытия цепи на cooldown назад, чтобы
+	// тест не ждал реального времени.
+	opCooldown
+)
+
+type breakerStep struct {
+	op breakerOp
+	// allowed проверяется только для opAllow.
+	allowed bool
+	state   string
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []breakerStep
+	}{
+		{
+			name: "opens after threshold failures in a row",
+			steps: []breakerStep{
+				{op: opFailure, state: breakerClosed},
+				{op: opFailure, state: breakerClosed},
+				{op: opAllow, allowed: true, state: breakerClosed},
+				{op: opFailure, state: breakerOpen},
+				{op: opAllow, allowed: false, state: breakerOpen},
+			},
+		},
+		{
+			name: "success resets failure count",
+			steps: []breakerStep{
+				{op: opFailure, state: breakerClosed},
+				{op: opFailure, state: breakerClosed},
+				{op: opSuccess, state: breakerClosed},
+				{op: opFailure, state: breakerClosed},
+				{op: opFailure, state: breakerClosed},
+				{op: opAllow, allowed: true, state: breakerClosed},
+			},
+		},
+		{
+			name: "open to half-open to closed",
+			steps: []breakerStep{
+				{op: opFailure}, {op: opFailure}, {op: opFailure, state: breakerOpen},
+				{op: opCooldown, state: breakerOpen},
+				{op: opAllow, allowed: true, state: breakerHalfOpen},
+				{op: opSuccess, state: breakerClosed},
+				{op: opAllow, allowed: true, state: breakerClosed},
+				{op: opAllow, allowed: true, state: breakerClosed},
+			},
+		},
+		{
+			name: "failed probe opens again",
+			steps: []breakerStep{
+				{op: opFailure}, {op: opFailure}, {op: opFailure, state: breakerOpen},
+				{op: opCooldown, state: breakerOpen},
+				{op: opAllow, allowed: true, state: breakerHalfOpen},
+				{op: opFailure, state: breakerOpen},
+				{op: opAllow, allowed: false, state: breakerOpen},
+			},
+		},
+		{
+			name: "half-open lets one probe through",
+			steps: []breakerStep{
+				{op: opFailure}, {op: opFailure}, {op: opFailure, state: breakerOpen},
+				{op: opCooldown, state: breakerOpen},
+				{op: opAllow, allowed: true, state: breakerHalfOpen},
+				{op: opAllow, allowed: false, state: breakerHalfOpen},
+				{op: opAllow, allowed: false, state: breakerHalfOpen},
+			},
+		},
+		{
+			name: "canceled probe frees the slot",
+			steps: []breakerStep{
+				{op: opFailure}, {op: opFailure}, {op: opFailure, state: breakerOpen},
+				{op: opCooldown, state: breakerOpen},
+				{op: opAllow, allowed: true, state: breakerHalfOpen},
+				{op: opCancel, state: breakerHalfOpen},
+				{op: opAllow, allowed: true, state: breakerHalfOpen},
+				{op: opAllow, allowed: false, state: breakerHalfOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(3, time.Hour)
+
+			for i, step := range tt.steps {
+				switch step.op {
+				case opAllow:
+					if err := b.Allow(); (err == nil) != step.allowed {
+						t.Fatalf("step %d: Allow() = %v, want allowed %v", i, err, step.allowed)
+					}
+				case opSuccess:
+					b.Success()
+				case opFailure:
+					b.Failure()
+				case opCancel:
+					b.Cancel()
+				case opCooldown:
+					b.mu.Lock()
+					b.openedAt = b.openedAt.Add(-b.cooldown)
+					b.mu.Unlock()
+				}
+				if step.state != "" && b.State() != step.state {
+					t.Fatalf("step %d: state = %s, want %s", i, b.State(), step.state)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerSingleProbeConcurrent(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Hour)
+	b.Failure()
+	b.mu.Lock()
+	b.openedAt = b.openedAt.Add(-b.cooldown)
+	b.mu.Unlock()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Allow() == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 1 {
+		t.Fatalf("allowed = %d probes, want 1", allowed)
+	}
+}
@@ -2,109 +2,291 @@ package proxy
 
 import (
 	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/middleware"
+	"api-gateway/internal/utils"
 	"bytes"
+	"common/gatewayauth"
+	"common/logging"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// identityTTL - срок жизни токена личности. Он выпускается на каждый
+// запрос, так что нужен лишь запас на расхождение часов.
+const identityTTL = 30 * time.Second
+
+// maxBufferedBody - тела до этого размера буферизуются: их можно отправить
+// повторно и найти в них поле привязки к экземпляру. Большие тела (загрузки
+// файлов) передаются потоком.
+const maxBufferedBody = 64 << 10
+
+var tracer = otel.Tracer("api-gateway/proxy")
+
+const (
+	ServiceDrones = "drones"
+	ServicePolice = "police"
+	ServiceMap    = "map"
 )
 
 type ProxyHandler struct {
-	droneServiceURL  string
-	policeServiceURL string
-	mapServiceURL    string
+	proxies   map[string]*httputil.ReverseProxy
+	upstreams map[string]*Upstream
+	routes    *RouteTable
+	keys      *utils.KeySet
+}
+
+type serviceSpec struct {
+	urls        []string
+	stickyField string
 }
 
-func NewProxyHandler() *ProxyHandler {
-	cfg := config.New()
-	return &ProxyHandler{
-		droneServiceURL:  cfg.DroneServiceURL,
-		policeServiceURL: cfg.PoliceServiceURL,
-		mapServiceURL:    cfg.MapServiceURL,
+func NewProxyHandler(cfg *config.Config, keys *utils.KeySet) (*ProxyHandler, error) {
+	services := map[string]serviceSpec{
+		// Полет симулирует один экземпляр. Остановить его можно с любого
+		// (текущий полет хранится в базе), но на своем экземпляре остановка
+		// не ждет следующего тика, поэтому запросы об одном дроне
+		// направляются туда же.
+		ServiceDrones: {urls: cfg.DroneServiceURLs, stickyField: "drone_id"},
+		ServicePolice: {urls: cfg.PoliceServiceURLs},
+		ServiceMap:    {urls: cfg.MapServiceURLs},
+	}
+
+	proxies := make(map[string]*httputil.ReverseProxy, len(services))
+	upstreams := make(map[string]*Upstream, len(services))
+	names := make([]string, 0, len(services))
+	for name, spec := range services {
+		upstream, err := NewUpstream(name, spec.urls, spec.stickyField, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("сервис %s: %v", name, err)
+		}
+		upstreams[name] = upstream
+		metrics.RegisterGauge("gateway_upstream_healthy_instances", "Число исправных экземпляров сервиса.",
+			prometheus.Labels{"service": name}, upstream.healthyCount)
+		proxies[name] = newReverseProxy(upstream)
+		names = append(names, name)
+	}
+
+	routes, err := LoadRouteTable(cfg.RoutesFile, names)
+	if err != nil {
+		return nil, err
 	}
+
+	return &ProxyHandler{proxies: proxies, upstreams: upstreams, routes: routes, keys: keys}, nil
 }
 
-func (p *ProxyHandler) ProxyToDroneService(c *gin.Context) {
-	p.proxyRequest(c, p.droneServiceURL)
+// WatchHealth запускает в фоне проверки экземпляров всех сервисов.
+func (p *ProxyHandler) WatchHealth(interval time.Duration) {
+	for _, upstream := range p.upstreams {
+		go upstream.WatchHealth(interval)
+	}
 }
 
-func (p *ProxyHandler) ProxyToPoliceService(c *gin.Context) {
-	p.proxyRequest(c, p.policeServiceURL)
+// Status возвращает состояние экземпляров всех сервисов по результатам
+// последних проверок.
+func (p *ProxyHandler) Status() map[string]ServiceStatus {
+	statuses := make(map[string]ServiceStatus, len(p.upstreams))
+	for name, upstream := range p.upstreams {
+		statuses[name] = upstream.Status()
+	}
+	return statuses
 }
 
-func (p *ProxyHandler) ProxyToMapService(c *gin.Context) {
-	p.proxyRequest(c, p.mapServiceURL)
+// WatchRoutes перечитывает таблицу маршрутов при изменении файла.
+func (p *ProxyHandler) WatchRoutes(interval time.Duration) {
+	p.routes.Watch(interval)
 }
 
-func (p *ProxyHandler) proxyRequest(c *gin.Context, targetURL string) {
-	userID, _ := c.Get("user_id")
-	roleID, _ := c.Get("role_id")
+// MatchRoute находит маршрут в таблице и сохраняет его в контексте для
+// следующих обработчиков цепочки.
+func (p *ProxyHandler) MatchRoute(c *gin.Context) {
+	route, methodAllowed := p.routes.Match(c.Request.Method, c.Request.URL.Path)
+	if route == nil {
+		if methodAllowed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		} else {
+			c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method not allowed"})
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set("route", route)
+	c.Set(metrics.RouteKey, route.Path)
+	c.Set(middleware.APIKeyScopeKey, route.Scope)
+	c.Next()
+}
 
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading request body"})
+// RouteAuth применяет auth только к маршрутам, которые требуют входа.
+func (p *ProxyHandler) RouteAuth(auth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if routeFrom(c).RequiresAuth() {
+			auth(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func (p *ProxyHandler) RoutePermission(c *gin.Context) {
+	if permission := routeFrom(c).Permission; permission != "" {
+		middleware.RequirePermission(permission)(c)
 		return
 	}
+	c.Next()
+}
 
-	var requestBody map[string]interface{}
-	if len(body) > 0 {
-		if err := json.Unmarshal(body, &requestBody); err != nil {
-			requestBody = make(map[string]interface{})
+func (p *ProxyHandler) Proxy(c *gin.Context) {
+	route := routeFrom(c)
+	if route.MaxBody > 0 {
+		if c.Request.ContentLength > route.MaxBody {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Тело запроса слишком большое"})
+			return
 		}
-	} else {
-		requestBody = make(map[string]interface{})
+		// Тело без Content-Length обрывается на лимите во время передачи.
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, route.MaxBody)
 	}
+	p.proxyRequest(c, route.Service)
+}
 
-	requestBody["user_id"] = userID
-	requestBody["user_role"] = roleID
+func routeFrom(c *gin.Context) *Route {
+	return c.MustGet("route").(*Route)
+}
 
-	modifiedBody, err := json.Marshal(requestBody)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error modifying request body"})
+// proxyRequest потоково пересылает запрос сервису, не меняя тело.
+// Личность пользователя передается в заголовке gatewayauth.Header, подписанном
+// ключом шлюза. Подпись привязана к длине и типу тела, а не к его хешу:
+// иначе тело пришлось бы читать целиком до отправки.
+func (p *ProxyHandler) proxyRequest(c *gin.Context, service string) {
+	if err := p.prepareBody(c.Request); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Тело запроса слишком большое"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading request body"})
 		return
 	}
 
-	url := targetURL + c.Request.URL.Path
-	if c.Request.URL.RawQuery != "" {
-		url += "?" + c.Request.URL.RawQuery
-	}
+	permissions, _ := c.Get("permissions")
+	permissionList, _ := permissions.([]string)
 
-	req, err := http.NewRequest(c.Request.Method, url, bytes.NewBuffer(modifiedBody))
+	identity, err := utils.GenerateIdentityToken(p.keys, gatewayauth.Identity{
+		UserID:        c.GetInt("user_id"),
+		RoleID:        c.GetInt("role_id"),
+		Permissions:   permissionList,
+		Method:        c.Request.Method,
+		Path:          c.Request.URL.Path,
+		ContentLength: c.Request.ContentLength,
+		ContentType:   c.Request.Header.Get("Content-Type"),
+	}, identityTTL)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating proxy request"})
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error signing identity"})
 		return
 	}
+	c.Request.Header.Set(gatewayauth.Header, identity)
 
-	for key, values := range c.Request.Header {
-		if key != "Authorization" {
-			for _, value := range values {
-				req.Header.Add(key, value)
-			}
+	if field := p.upstreams[service].stickyField; field != "" {
+		if key := stickyValue(c.Request, field); key != "" {
+			c.Request = c.Request.WithContext(logging.With(withStickyKey(c.Request.Context(), key), field, key))
 		}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	ctx, span := tracer.Start(c.Request.Context(), "proxy "+service,
+		trace.WithAttributes(attribute.String("gateway.service", service)))
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Service unavailable"})
-		return
+	c.Set(middleware.ProxiedKey, true)
+	p.proxies[service].ServeHTTP(c.Writer, c.Request)
+}
+
+// prepareBody читает начало тела. Если тело целиком помещается в
+// maxBufferedBody, оно буферизуется для повторной отправки, иначе
+// прочитанное склеивается с остатком и тело идет потоком.
+func (p *ProxyHandler) prepareBody(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	head, err := io.ReadAll(io.LimitReader(r.Body, maxBufferedBody+1))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading response"})
-		return
+		return err
 	}
 
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
-		}
+	if len(head) > maxBufferedBody {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+		return nil
 	}
 
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(head))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(head)), nil
+	}
+	r.ContentLength = int64(len(head))
+	return nil
+}
+
+func newReverseProxy(upstream *Upstream) *httputil.ReverseProxy {
+	target := upstream.baseURL()
+	return &httputil.ReverseProxy{
+		Transport: upstream,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			// Учетные данные клиента сервисам не нужны: личность передается
+			// в подписанном заголовке.
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("X-Api-Key")
+		},
+		// CORS и X-Request-ID отвечает шлюз; заголовки сервисов дублировали бы его.
+		ModifyResponse: func(resp *http.Response) error {
+			for key := range resp.Header {
+				if strings.HasPrefix(key, "Access-Control-") {
+					resp.Header.Del(key)
+				}
+			}
+			resp.Header.Del(middleware.RequestIDHeader)
+			return nil
+		},
+		// Ответы без Content-Length и SSE отдаются клиенту сразу по частям.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// Клиент ушел сам - отвечать некому, и сервис в этом не виноват.
+			if errors.Is(r.Context().Err(), context.Canceled) {
+				return
+			}
+
+			status, code, message := classifyError(err)
+			slog.ErrorContext(r.Context(), "Proxy error", "service", upstream.name, "method", r.Method, "path", r.URL.Path, "code", code, "error", err)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(gin.H{
+				"error":      message,
+				"code":       code,
+				"service":    upstream.name,
+				"request_id": r.Header.Get(middleware.RequestIDHeader),
+			})
+		},
+	}
 }
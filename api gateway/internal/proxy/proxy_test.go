@@ -0,0 +1,231 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/utils"
+	"common/gatewayauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestGateway поднимает шлюз с одним сервисом drones перед backend.
+// Backend принимает запросы только с подписью шлюза, как настоящие сервисы.
+func newTestGateway(t *testing.T, routes string, backend http.Handler) *httptest.Server {
+	t.Helper()
+
+	keys, err := utils.LoadKeySet(t.TempDir(), "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keys.JWKS())
+	}))
+	t.Cleanup(jwks.Close)
+
+	service := httptest.NewServer(gatewayauth.NewVerifier(jwks.URL, nil).Middleware(backend))
+	t.Cleanup(service.Close)
+
+	upstream, err := NewUpstream(ServiceDrones, []string{service.URL}, "", &config.Config{
+		LoadBalancing:           "round-robin",
+		UpstreamDialTimeout:     time.Second,
+		UpstreamResponseTimeout: 5 * time.Second,
+		UpstreamMaxIdleConns:    1,
+		BreakerFailureThreshold: 5,
+		BreakerCooldown:         time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeRoutes(t, path, routes, time.Now())
+	table, err := LoadRouteTable(path, []string{ServiceDrones})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &ProxyHandler{
+		proxies:   map[string]*httputil.ReverseProxy{ServiceDrones: newReverseProxy(upstream)},
+		upstreams: map[string]*Upstream{ServiceDrones: upstream},
+		routes:    table,
+		keys:      keys,
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/api/*path", p.MatchRoute, p.Proxy)
+
+	gateway := httptest.NewServer(router)
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func TestProxyStreamsLargeBody(t *testing.T) {
+	const (
+		total = 3 << 20
+		chunk = 32 << 10
+	)
+	// Сервис должен получить первые 2 МиБ, пока клиент еще не дописал тело:
+	// если шлюз буферизует тело целиком, тест упрется в таймаут.
+	const streamedBefore = 2 << 20
+
+	received := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int64
+		buf := make([]byte, chunk)
+		signaled := false
+		for {
+			read, err := r.Body.Read(buf)
+			n += int64(read)
+			if !signaled && n >= streamedBefore {
+				close(received)
+				signaled = true
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		fmt.Fprint(w, n)
+	})
+	gateway := newTestGateway(t, "routes:\n  - {path: /api/upload, methods: [POST], service: drones, auth: false}\n", backend)
+
+	body, writer := io.Pipe()
+	go func() {
+		data := bytes.Repeat([]byte("a"), chunk)
+		for written := 0; written < total; written += chunk {
+			if written == streamedBefore+chunk {
+				select {
+				case <-received:
+				case <-time.After(5 * time.Second):
+					writer.CloseWithError(fmt.Errorf("body was not streamed to the service"))
+					return
+				}
+			}
+			if _, err := writer.Write(data); err != nil {
+				return
+			}
+		}
+		writer.Close()
+	}()
+
+	resp, err := http.Post(gateway.URL+"/api/upload", "application/octet-stream", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	got, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.StatusCode, got)
+	}
+	if string(got) != fmt.Sprint(total) {
+		t.Fatalf("service received %s bytes, want %d", got, total)
+	}
+}
+
+func TestProxyRouteBodyLimit(t *testing.T) {
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	})
+	gateway := newTestGateway(t, "routes:\n  - {path: /api/upload, methods: [POST], service: drones, auth: false, max_body: 1024}\n", backend)
+
+	tests := []struct {
+		name       string
+		body       io.Reader
+		wantStatus int
+	}{
+		{name: "within limit", body: strings.NewReader(strings.Repeat("a", 1024)), wantStatus: http.StatusOK},
+		{name: "content length over limit", body: strings.NewReader(strings.Repeat("a", 1025)), wantStatus: http.StatusRequestEntityTooLarge},
+		// Без Content-Length лимит срабатывает при чтении тела.
+		{name: "chunked over limit", body: io.MultiReader(strings.NewReader(strings.Repeat("a", 4096))), wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(gateway.URL+"/api/upload", "application/octet-stream", tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestProxyFlushesServerSentEvents(t *testing.T) {
+	firstRead := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+
+		// Второе событие отправляется, только когда клиент получил первое:
+		// без сброса буфера на шлюзе клиент его не дождется.
+		select {
+		case <-firstRead:
+		case <-time.After(5 * time.Second):
+		}
+		fmt.Fprint(w, "data: second\n\n")
+	})
+	gateway := newTestGateway(t, "routes:\n  - {path: /api/events, methods: [GET], service: drones, auth: false}\n", backend)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(gateway.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string)
+	go func() {
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- strings.TrimSpace(line)
+		}
+	}()
+
+	select {
+	case line := <-lines:
+		if line != "data: first" {
+			t.Fatalf("first line = %q", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("first event was not flushed to the client")
+	}
+	close(firstRead)
+
+	var rest []string
+	for line := range lines {
+		if line != "" {
+			rest = append(rest, line)
+		}
+	}
+	if len(rest) != 1 || rest[0] != "data: second" {
+		t.Fatalf("rest = %q, want second event", rest)
+	}
+}
@@ -0,0 +1,201 @@
+package proxy
+
+import (
+	"api-gateway/internal/models"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Route struct {
+	Path       string   `yaml:"path" json:"path"`
+	Methods    []string `yaml:"methods" json:"methods"`
+	Service    string   `yaml:"service" json:"service"`
+	Auth       *bool    `yaml:"auth" json:"auth"`
+	Permission string   `yaml:"permission" json:"permission"`
+	// Scope - область API-ключа, открывающая маршрут. Без нее маршрут
+	// доступен только по JWT.
+	Scope string `yaml:"scope" json:"scope"`
+	// MaxBody - наибольший размер тела запроса в байтах, 0 - без ограничения.
+	MaxBody int64 `yaml:"max_body" json:"max_body"`
+
+	segments []string
+}
+
+type routeFile struct {
+	Routes []Route `yaml:"routes" json:"routes"`
+}
+
+// RequiresAuth - маршруты по умолчанию закрыты, открывать их нужно явно.
+func (r *Route) RequiresAuth() bool {
+	return r.Auth == nil || *r.Auth
+}
+
+func (r *Route) allowsMethod(method string) bool {
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// match сравнивает путь с шаблоном по сегментам: ":name" совпадает с любым
+// одним сегментом, "*" в конце шаблона - с любым остатком пути.
+func (r *Route) match(segments []string) bool {
+	for i, pattern := range r.segments {
+		if pattern == "*" && i == len(r.segments)-1 {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(pattern, ":") && pattern != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(r.segments)
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// RouteTable - таблица маршрутов из файла. При изменении файла таблица
+// перечитывается; если новая версия содержит ошибки, продолжает работать
+// предыдущая.
+type RouteTable struct {
+	path     string
+	services map[string]bool
+
+	mu      sync.RWMutex
+	routes  []Route
+	modTime time.Time
+}
+
+func LoadRouteTable(path string, services []string) (*RouteTable, error) {
+	t := &RouteTable{path: path, services: make(map[string]bool)}
+	for _, s := range services {
+		t.services[s] = true
+	}
+
+	if _, err := t.reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Match находит маршрут для запроса. Второе значение false, если путь
+// известен, но метод не разрешен - это отличает 405 от 404.
+func (t *RouteTable) Match(method, path string) (*Route, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	segments := splitPath(path)
+	pathKnown := false
+	for i := range t.routes {
+		route := &t.routes[i]
+		if !route.match(segments) {
+			continue
+		}
+		if route.allowsMethod(method) {
+			return route, true
+		}
+		pathKnown = true
+	}
+	return nil, !pathKnown
+}
+
+// Watch проверяет время изменения файла раз в interval и перечитывает таблицу.
+func (t *RouteTable) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		reloaded, err := t.reload()
+		if err != nil {
+			slog.Error("Failed to reload routes, keeping previous table", "path", t.path, "error", err)
+			continue
+		}
+		if reloaded {
+			slog.Info("Routes reloaded", "path", t.path)
+		}
+	}
+}
+
+func (t *RouteTable) reload() (bool, error) {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.RLock()
+	unchanged := info.ModTime().Equal(t.modTime)
+	t.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	routes, err := t.parse()
+	if err != nil {
+		// Запоминаем время изменения, чтобы не повторять ошибку в логе
+		// до следующей правки файла.
+		t.mu.Lock()
+		t.modTime = info.ModTime()
+		t.mu.Unlock()
+		return false, err
+	}
+
+	t.mu.Lock()
+	t.routes = routes
+	t.modTime = info.ModTime()
+	t.mu.Unlock()
+
+	return true, nil
+}
+
+func (t *RouteTable) parse() ([]Route, error) {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file routeFile
+	if filepath.Ext(t.path) == ".json" {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range file.Routes {
+		route := &file.Routes[i]
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("маршрут %d: путь должен начинаться с /", i+1)
+		}
+		if len(route.Methods) == 0 {
+			return nil, fmt.Errorf("маршрут %s: не указаны методы", route.Path)
+		}
+		if !t.services[route.Service] {
+			return nil, fmt.Errorf("маршрут %s: неизвестный сервис %q", route.Path, route.Service)
+		}
+		if route.Scope != "" && !models.IsValidScope(route.Scope) {
+			return nil, fmt.Errorf("маршрут %s: неизвестная область API-ключа %q", route.Path, route.Scope)
+		}
+		if route.MaxBody < 0 {
+			return nil, fmt.Errorf("маршрут %s: max_body не может быть отрицательным", route.Path)
+		}
+		route.segments = splitPath(route.Path)
+	}
+
+	return file.Routes, nil
+}
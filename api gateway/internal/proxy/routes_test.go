@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRouteMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "/api/drone/create", path: "/api/drone/create", want: true},
+		{pattern: "/api/drone/create", path: "/api/drone/create/", want: true},
+		{pattern: "/api/drone/create", path: "/api/drone/move", want: false},
+		{pattern: "/api/drone/create", path: "/api/drone", want: false},
+		{pattern: "/api/drone/create", path: "/api/drone/create/extra", want: false},
+		{pattern: "/api/drone/:id", path: "/api/drone/42", want: true},
+		{pattern: "/api/drone/:id", path: "/api/drone/42/info", want: false},
+		{pattern: "/api/drone/:id/info", path: "/api/drone/42/info", want: true},
+		{pattern: "/api/map/*", path: "/api/map/zones/1", want: true},
+		{pattern: "/api/map/*", path: "/api/map/zones", want: true},
+		{pattern: "/api/map/*", path: "/api/drone/zones", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			route := Route{Path: tt.pattern, segments: splitPath(tt.pattern)}
+			if got := route.match(splitPath(tt.path)); got != tt.want {
+				t.Fatalf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const testRoutes = `
+routes:
+  - {path: /api/drone/create, methods: [POST], service: drones, scope: drones}
+  - {path: /api/map, methods: [GET], service: map, auth: false}
+`
+
+func writeRoutes(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Таблица перечитывается по времени изменения; в тестах оно задается
+	// явно, чтобы не зависеть от точности часов файловой системы.
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRouteTableMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeRoutes(t, path, testRoutes, time.Now())
+
+	table, err := LoadRouteTable(path, []string{"drones", "map"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	route, ok := table.Match("POST", "/api/drone/create")
+	if route == nil || !ok {
+		t.Fatalf("POST /api/drone/create: route = %v, ok = %v", route, ok)
+	}
+	if route.Scope != "drones" || !route.RequiresAuth() {
+		t.Fatalf("route = %+v, want scope drones and auth", route)
+	}
+
+	if route, ok := table.Match("GET", "/api/drone/create"); route != nil || ok {
+		t.Fatalf("GET /api/drone/create: route = %v, ok = %v, want method not allowed", route, ok)
+	}
+	if route, ok := table.Match("GET", "/api/unknown"); route != nil || !ok {
+		t.Fatalf("GET /api/unknown: route = %v, ok = %v, want not found", route, ok)
+	}
+	if route, _ := table.Match("GET", "/api/map"); route == nil || route.RequiresAuth() || route.Scope != "" {
+		t.Fatalf("GET /api/map: route = %+v, want public route without scope", route)
+	}
+}
+
+func TestLoadRouteTableRejectsInvalidRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "relative path", content: "routes:\n  - {path: api/drone, methods: [GET], service: drones}\n"},
+		{name: "no methods", content: "routes:\n  - {path: /api/drone, service: drones}\n"},
+		{name: "unknown service", content: "routes:\n  - {path: /api/drone, methods: [GET], service: billing}\n"},
+		{name: "unknown scope", content: "routes:\n  - {path: /api/drone, methods: [GET], service: drones, scope: billing}\n"},
+		{name: "malformed yaml", content: "routes: [\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "routes.yaml")
+			writeRoutes(t, path, tt.content, time.Now())
+
+			if _, err := LoadRouteTable(path, []string{"drones"}); err == nil {
+				t.Fatal("invalid table accepted")
+			}
+		})
+	}
+}
+
+func TestRouteTableReloadKeepsPreviousTableOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	start := time.Now().Add(-time.Hour)
+	writeRoutes(t, path, testRoutes, start)
+
+	table, err := LoadRouteTable(path, []string{"drones", "map"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeRoutes(t, path, "routes:\n  - {path: /api/drone/create, methods: [POST], service: billing}\n", start.Add(time.Minute))
+	if reloaded, err := table.reload(); err == nil || reloaded {
+		t.Fatalf("reload = %v, %v, want error", reloaded, err)
+	}
+	if route, _ := table.Match("POST", "/api/drone/create"); route == nil || route.Service != "drones" {
+		t.Fatalf("after failed reload: route = %+v, want previous drones route", route)
+	}
+
+	// Ошибочная версия не перечитывается, пока файл не изменят снова.
+	if reloaded, err := table.reload(); err != nil || reloaded {
+		t.Fatalf("repeated reload = %v, %v, want no-op", reloaded, err)
+	}
+
+	writeRoutes(t, path, "routes:\n  - {path: /api/drone/move, methods: [POST], service: drones}\n", start.Add(2*time.Minute))
+	if reloaded, err := table.reload(); err != nil || !reloaded {
+		t.Fatalf("reload = %v, %v, want new table", reloaded, err)
+	}
+	if route, _ := table.Match("POST", "/api/drone/move"); route == nil {
+		t.Fatal("new route not found after reload")
+	}
+	if route, ok := table.Match("POST", "/api/drone/create"); route != nil || !ok {
+		t.Fatalf("removed route still matches: route = %+v", route)
+	}
+}
@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/metrics"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const retryBaseDelay = 100 * time.Millisecond
+
+// Upstream - транспорт к одному сервису: собственный пул соединений,
+// таймауты, повторы идемпотентных запросов и балансировка между
+// экземплярами сервиса с circuit breaker у каждого.
+type Upstream struct {
+	name      string
+	transport *http.Transport
+	// traced - transport с клиентским спаном на каждую попытку и передачей
+	// контекста трассировки сервису.
+	traced   http.RoundTripper
+	balancer *balancer
+	retries  int
+	// stickyField - поле запроса, по которому запросы привязываются к
+	// экземпляру. Пустое - без привязки.
+	stickyField string
+
+	healthClient   *http.Client
+	healthPath     string
+	unhealthyAfter int
+}
+
+func NewUpstream(name string, urls []string, stickyField string, cfg *config.Config) (*Upstream, error) {
+	b, err := newBalancer(urls, cfg.LoadBalancing, cfg.BreakerFailureThreshold, cfg.BreakerCooldown)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.UpstreamDialTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        cfg.UpstreamMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.UpstreamMaxIdleConns,
+		IdleConnTimeout:     90 * time.Second,
+		// Ограничивается ожидание заголовков ответа, а не весь ответ,
+		// чтобы не обрывать потоковые ответы.
+		ResponseHeaderTimeout: cfg.UpstreamResponseTimeout,
+	}
+
+	return &Upstream{
+		name:      name,
+		transport: transport,
+		traced: otelhttp.NewTransport(transport, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "upstream " + name + " " + r.Method
+		})),
+		balancer:       b,
+		retries:        cfg.UpstreamRetries,
+		stickyField:    stickyField,
+		healthClient:   &http.Client{Transport: transport, Timeout: cfg.HealthCheckTimeout},
+		healthPath:     cfg.HealthCheckPath,
+		unhealthyAfter: cfg.HealthCheckFailures,
+	}, nil
+}
+
+// baseURL - адрес, который ReverseProxy подставляет в запрос. Хост потом
+// заменяется адресом выбранного экземпляра.
+func (u *Upstream) baseURL() *url.URL {
+	return u.balancer.instances[0].url
+}
+
+// WatchHealth проверяет экземпляры сервиса сразу и затем раз в interval.
+func (u *Upstream) WatchHealth(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	u.balancer.checkHealth(u.healthClient, u.name, u.healthPath, u.unhealthyAfter)
+	for range ticker.C {
+		u.balancer.checkHealth(u.healthClient, u.name, u.healthPath, u.unhealthyAfter)
+	}
+}
+
+type ServiceStatus struct {
+	// Status: up - все экземпляры исправны, degraded - часть, down - ни одного.
+	Status    string           `json:"status"`
+	Instances []InstanceStatus `json:"instances"`
+}
+
+func (u *Upstream) Status() ServiceStatus {
+	status := ServiceStatus{Instances: make([]InstanceStatus, 0, len(u.balancer.instances))}
+
+	healthy := 0
+	for _, inst := range u.balancer.instances {
+		instStatus := inst.status()
+		if instStatus.Healthy {
+			healthy++
+		}
+		status.Instances = append(status.Instances, instStatus)
+	}
+
+	switch healthy {
+	case len(status.Instances):
+		status.Status = "up"
+	case 0:
+		status.Status = "down"
+	default:
+		status.Status = "degraded"
+	}
+	return status
+}
+
+func (u *Upstream) healthyCount() float64 {
+	count := 0
+	for _, inst := range u.balancer.instances {
+		if inst.healthy.Load() {
+			count++
+		}
+	}
+	return float64(count)
+}
+
+func (u *Upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	stickyKey := stickyKeyFrom(req.Context())
+	tried := make(map[*instance]bool)
+
+	for attempt := 0; ; attempt++ {
+		inst, err := u.acquire(stickyKey, tried)
+		if err != nil {
+			metrics.UpstreamRequests.WithLabelValues(u.name, outcome(err)).Inc()
+			return nil, err
+		}
+		tried[inst] = true
+
+		outreq := req.Clone(req.Context())
+		outreq.URL.Scheme = inst.url.Scheme
+		outreq.URL.Host = inst.url.Host
+
+		atomic.AddInt64(&inst.active, 1)
+		resp, err := u.traced.RoundTrip(outreq)
+		if err != nil {
+			atomic.AddInt64(&inst.active, -1)
+		} else {
+			resp.Body = newCountingBody(resp.Body, inst)
+		}
+
+		switch {
+		case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+			inst.breaker.Cancel()
+			metrics.UpstreamRequests.WithLabelValues(u.name, "canceled").Inc()
+			return nil, err
+		case err != nil || isUpstreamFailure(resp.StatusCode):
+			inst.breaker.Failure()
+			metrics.UpstreamRequests.WithLabelValues(u.name, "error").Inc()
+		default:
+			inst.breaker.Success()
+			metrics.UpstreamRequests.WithLabelValues(u.name, "ok").Inc()
+			return resp, nil
+		}
+
+		if attempt >= u.retries || !canRetry(req) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		if err := u.rewindBody(req); err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// acquire выбирает экземпляр, цепь которого пропускает запрос. Экземпляры
+// из tried, где запрос уже не удался, берутся, только если других нет.
+func (u *Upstream) acquire(stickyKey string, tried map[*instance]bool) (*instance, error) {
+	skip := make(map[*instance]bool, len(tried))
+	for inst := range tried {
+		skip[inst] = true
+	}
+
+	retryTried := len(tried) > 0
+	breakerOpen := false
+	for {
+		inst, err := u.balancer.pick(stickyKey, skip)
+		if errors.Is(err, ErrNoHealthyInstances) && retryTried {
+			for inst := range tried {
+				delete(skip, inst)
+			}
+			retryTried = false
+			continue
+		}
+		if err != nil {
+			if breakerOpen {
+				return nil, ErrCircuitOpen
+			}
+			return nil, err
+		}
+
+		if inst.breaker.Allow() == nil {
+			return inst, nil
+		}
+		breakerOpen = true
+		skip[inst] = true
+	}
+}
+
+func (u *Upstream) rewindBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
+
+// outcome - метка метрики для запроса, не отправленного ни одному экземпляру.
+func outcome(err error) string {
+	if errors.Is(err, ErrCircuitOpen) {
+		return "circuit_open"
+	}
+	return "no_healthy_instances"
+}
+
+// isUpstreamFailure - ответы, означающие проблему сервиса, а не запроса.
+func isUpstreamFailure(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// canRetry: повторяются только идемпотентные запросы, тело которых можно
+// отправить заново, и не upgrade-запросы.
+func canRetry(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if req.Header.Get("Upgrade") != "" {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// backoff - экспоненциальная задержка с полным джиттером, чтобы повторы
+// разных клиентов не приходили одновременно.
+func backoff(attempt int) time.Duration {
+	max := retryBaseDelay << attempt
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// classifyError сопоставляет ошибку проксирования с ответом клиенту.
+func classifyError(err error) (int, string, string) {
+	var netErr net.Error
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge, "request_too_large", "Тело запроса слишком большое"
+	case errors.Is(err, ErrNoHealthyInstances):
+		return http.StatusServiceUnavailable, "no_healthy_instances", "Нет доступных экземпляров сервиса"
+	case errors.Is(err, ErrCircuitOpen):
+		return http.StatusServiceUnavailable, "circuit_open", "Сервис временно отключен после серии ошибок"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusGatewayTimeout, "upstream_timeout", "Сервис не ответил вовремя"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return http.StatusBadGateway, "upstream_refused", "Сервис недоступен"
+	}
+	return http.StatusBadGateway, "upstream_error", "Ошибка соединения с сервисом"
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// newTestUpstream поднимает экземпляры, которые всегда отвечают status, и
+// возвращает Upstream к ним и счетчик запросов.
+func newTestUpstream(t *testing.T, instances, status int) (*Upstream, *int64) {
+	t.Helper()
+
+	var hits int64
+	urls := make([]string, 0, instances)
+	for i := 0; i < instances; i++ {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&hits, 1)
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(status)
+			w.Write(body)
+		}))
+		t.Cleanup(srv.Close)
+		urls = append(urls, srv.URL)
+	}
+
+	u, err := NewUpstream("test", urls, "", &config.Config{
+		LoadBalancing:           "round-robin",
+		UpstreamDialTimeout:     time.Second,
+		UpstreamResponseTimeout: time.Second,
+		UpstreamRetries:         2,
+		UpstreamMaxIdleConns:    1,
+		BreakerFailureThreshold: 100,
+		BreakerCooldown:         time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u, &hits
+}
+
+func TestUpstreamRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     []byte
+		status   int
+		wantHits int64
+	}{
+		{name: "GET retried", method: http.MethodGet, status: http.StatusServiceUnavailable, wantHits: 3},
+		{name: "PUT with body retried", method: http.MethodPut, body: []byte(`{"name":"x"}`), status: http.StatusBadGateway, wantHits: 3},
+		{name: "POST not retried", method: http.MethodPost, body: []byte(`{"drone_id":1}`), status: http.StatusServiceUnavailable, wantHits: 1},
+		{name: "PATCH not retried", method: http.MethodPatch, body: []byte(`{}`), status: http.StatusGatewayTimeout, wantHits: 1},
+		{name: "client error not retried", method: http.MethodGet, status: http.StatusBadRequest, wantHits: 1},
+		{name: "success", method: http.MethodGet, status: http.StatusOK, wantHits: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, hits := newTestUpstream(t, 2, tt.status)
+
+			req := httptest.NewRequest(tt.method, "http://gateway/api/test", nil)
+			req.RequestURI = ""
+			if tt.body != nil {
+				// Так тело готовит prepareBody: повтор берет его из GetBody.
+				body := tt.body
+				req.GetBody = func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(body)), nil
+				}
+				req.Body, _ = req.GetBody()
+				req.ContentLength = int64(len(body))
+			}
+
+			resp, err := u.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if !bytes.Equal(got, tt.body) {
+				t.Fatalf("body = %q, want %q: body not resent on retry", got, tt.body)
+			}
+			if n := atomic.LoadInt64(hits); n != tt.wantHits {
+				t.Fatalf("upstream hits = %d, want %d", n, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestUpstreamDoesNotRetryUpgrade(t *testing.T) {
+	u, hits := newTestUpstream(t, 2, http.StatusServiceUnavailable)
+
+	req := httptest.NewRequest(http.MethodGet, "http://gateway/api/ws", nil)
+	req.RequestURI = ""
+	req.Header.Set("Upgrade", "websocket")
+
+	resp, err := u.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+
+	if n := atomic.LoadInt64(hits); n != 1 {
+		t.Fatalf("upstream hits = %d, want 1", n)
+	}
+}
+
+func TestUpstreamUpgrade(t *testing.T) {
+	// Сервис переключает протокол и возвращает эхом все, что получил.
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		io.Copy(conn, rw)
+	}))
+	t.Cleanup(backend.Close)
+
+	u, err := NewUpstream("test", []string{backend.URL}, "", &config.Config{
+		LoadBalancing:           "round-robin",
+		UpstreamDialTimeout:     time.Second,
+		UpstreamResponseTimeout: time.Second,
+		UpstreamMaxIdleConns:    1,
+		BreakerFailureThreshold: 1,
+		BreakerCooldown:         time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := httptest.NewServer(newReverseProxy(u))
+	t.Cleanup(gateway.Close)
+
+	conn, err := net.Dial("tcp", gateway.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprint(conn, "GET /api/ws HTTP/1.1\r\nHost: gateway\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+
+	const message = "ping\n"
+	fmt.Fprint(conn, message)
+	got, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != message {
+		t.Fatalf("echo = %q, want %q", got, message)
+	}
+
+	inst := u.balancer.instances[0]
+	if state := inst.breaker.State(); state != breakerClosed {
+		t.Fatalf("breaker = %s, want closed", state)
+	}
+
+	// Соединение освобождается, когда клиент его закрывает.
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&inst.active) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("active = %d after close, want 0", atomic.LoadInt64(&inst.active))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
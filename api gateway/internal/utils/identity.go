@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"time"
+
+	"common/gatewayauth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// GenerateIdentityToken подписывает личность пользователя для сервисов.
+// Audience отличает такой токен от access-токена пользователя: сервисы
+// принимают только его, а шлюз его не принимает.
+func GenerateIdentityToken(keys *KeySet, identity gatewayauth.Identity, ttl time.Duration) (string, error) {
+	now := time.Now()
+	identity.Audience = jwt.ClaimStrings{gatewayauth.Audience}
+	identity.IssuedAt = jwt.NewNumericDate(now)
+	identity.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	return keys.Sign(identity)
+}
@@ -1,44 +1,78 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenTimePrecision - точность iat и exp в токенах. Секунд недостаточно:
+// токен, выданный сразу после отзыва всех сессий (например, при смене
+// пароля), нельзя было бы отличить от выданного до отзыва.
+const TokenTimePrecision = time.Microsecond
+
+func init() {
+	jwt.TimePrecision = TokenTimePrecision
+}
+
 type Claims struct {
-	UserID int `json:"user_id"`
-	RoleID int `json:"role_id"`
+	UserID      int      `json:"user_id"`
+	RoleID      int      `json:"role_id"`
+	Permissions []string `json:"permissions"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID, roleID int, secret string) (string, error) {
+func GenerateJWT(userID, roleID int, permissions []string, keys *KeySet, ttl time.Duration) (string, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
 	claims := Claims{
-		UserID: userID,
-		RoleID: roleID,
+		UserID:      userID,
+		RoleID:      roleID,
+		Permissions: permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keys.Sign(claims)
 }
 
-func ValidateJWT(tokenString, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+func ValidateJWT(tokenString string, keys *KeySet) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	// Токены с audience выпускаются шлюзом для сервисов и не являются
+	// пользовательскими access-токенами.
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && len(claims.Audience) == 0 {
 		return claims, nil
 	}
 
 	return nil, errors.New("invalid token")
 }
+
+// RandomToken возвращает n случайных байт в hex.
+func RandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashToken - SHA-256 от непрозрачного токена для хранения в базе.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
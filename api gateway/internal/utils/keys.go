@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey - ключ подписи JWT. У ключей, оставленных только для проверки
+// (после ротации), Private равен nil.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet хранит все действующие ключи. Подписывает всегда текущий ключ,
+// а проверка принимает любой ключ набора - так старые токены остаются
+// валидными до истечения срока после ротации.
+type KeySet struct {
+	current *SigningKey
+	keys    map[string]*SigningKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeySet читает ключи из каталога dir. Имя файла без расширения
+// становится kid: <kid>.pem - закрытый ключ (PKCS#8 RSA/Ed25519 или
+// PKCS#1 RSA), <kid>.pub.pem - открытый ключ, только для проверки.
+// Подписывает ключ signingKID, а если он не задан - последний по имени,
+// поэтому удобно называть ключи по дате выпуска. Если закрытых ключей нет,
+// Ed25519 ключ генерируется только при generateDevKey (локальная
+// разработка), иначе возвращается ошибка: ключ, выпущенный на одном
+// экземпляре шлюза, другие экземпляры и сервисы не знают.
+func LoadKeySet(dir, signingKID string, generateDevKey bool) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*SigningKey)}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var privateIDs []string
+	for _, path := range paths {
+		name := filepath.Base(path)
+		publicOnly := strings.HasSuffix(name, ".pub.pem")
+		kid := strings.TrimSuffix(strings.TrimSuffix(name, ".pem"), ".pub")
+
+		if _, exists := set.keys[kid]; exists {
+			return nil, fmt.Errorf("ключ %s задан несколько раз", kid)
+		}
+
+		key, err := loadKeyFile(path, kid, publicOnly)
+		if err != nil {
+			return nil, err
+		}
+		set.keys[kid] = key
+		if !publicOnly {
+			privateIDs = append(privateIDs, kid)
+		}
+	}
+
+	if len(privateIDs) == 0 {
+		if !generateDevKey {
+			return nil, fmt.Errorf("в каталоге %s нет закрытых ключей подписи JWT", dir)
+		}
+		key, err := newDevKey(dir)
+		if err != nil {
+			return nil, err
+		}
+		set.keys[key.ID] = key
+		privateIDs = append(privateIDs, key.ID)
+	}
+
+	if signingKID == "" {
+		sort.Strings(privateIDs)
+		signingKID = privateIDs[len(privateIDs)-1]
+	}
+
+	current, ok := set.keys[signingKID]
+	if !ok || current.Private == nil {
+		return nil, fmt.Errorf("закрытый ключ %s не найден в %s", signingKID, dir)
+	}
+	set.current = current
+
+	return set, nil
+}
+
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.current.Method, claims)
+	token.Header["kid"] = s.current.ID
+	return token.SignedString(s.current.Private)
+}
+
+// Keyfunc выбирает ключ проверки по заголовку kid и следит, чтобы алгоритм
+// токена совпадал с типом ключа.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// Methods - алгоритмы, которые допускаются при разборе токенов.
+func (s *KeySet) Methods() []string {
+	return []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+}
+
+func (s *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		ids = append(ids, kid)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(ids))}
+	for _, kid := range ids {
+		key := s.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+func loadKeyFile(path, kid string, publicOnly bool) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: не найден PEM блок", path)
+	}
+
+	var parsed interface{}
+	switch {
+	case publicOnly:
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case block.Type == "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	key := &SigningKey{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("%s: поддерживаются только RSA и Ed25519 ключи", path)
+	}
+
+	if rsaKey, ok := key.Public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New(path + ": RSA ключ должен быть не короче 2048 бит")
+	}
+
+	return key, nil
+}
+
+func newDevKey(dir string) (*SigningKey, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := time.Now().UTC().Format("20060102-150405")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, err
+	}
+	slog.Warn("No JWT signing keys found, generated development key", "path", path)
+
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: private.Public()}, nil
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret - ключ "12345678901234567890" из приложения B RFC 6238 в base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Векторы SHA-1 из приложения B RFC 6238. В RFC коды из 8 цифр, у нас
+// 6 цифр - это младшие разряды того же значения.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestValidateTOTPRFC6238Vectors(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step, ok := ValidateTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0), 0)
+		if !ok {
+			t.Errorf("t=%d: code %s rejected", v.unix, v.code)
+			continue
+		}
+		if want := v.unix / totpPeriod; step != want {
+			t.Errorf("t=%d: step = %d, want %d", v.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	// Код для t=1111111109 (шаг 37037036) принимается на соседнем шаге и
+	// не принимается через два шага.
+	const code = "081804"
+	base := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{name: "previous step", now: base.Add(-totpPeriod * time.Second), want: true},
+		{name: "next step", now: base.Add(totpPeriod * time.Second), want: true},
+		{name: "two steps later", now: base.Add(2 * totpPeriod * time.Second), want: false},
+		{name: "two steps earlier", now: base.Add(-2 * totpPeriod * time.Second), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(rfc6238Secret, code, tt.now, 0); ok != tt.want {
+				t.Fatalf("ok = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := ValidateTOTP(rfc6238Secret, "081804", now, 0)
+	if !ok {
+		t.Fatal("first use rejected")
+	}
+
+	if _, ok := ValidateTOTP(rfc6238Secret, "081804", now, step); ok {
+		t.Fatal("same code accepted twice")
+	}
+
+	// Код предыдущего шага еще в окне расхождения часов, но старше
+	// принятого и тоже отклоняется.
+	previous := totpCode(mustDecodeSecret(t, rfc6238Secret), step-1)
+	if _, ok := ValidateTOTP(rfc6238Secret, previous, now, step); ok {
+		t.Fatal("code of an earlier step accepted after a later one")
+	}
+
+	next := totpCode(mustDecodeSecret(t, rfc6238Secret), step+1)
+	if got, ok := ValidateTOTP(rfc6238Secret, next, now, step); !ok || got != step+1 {
+		t.Fatalf("next step code: step = %d, ok = %v, want %d, true", got, ok, step+1)
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	for _, code := range []string{"", "28708", "2870821", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now, 0); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", "287082", now, 0); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func mustDecodeSecret(t *testing.T, secret string) []byte {
+	t.Helper()
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
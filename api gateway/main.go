@@ -1,88 +1,198 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"api-gateway/internal/config"
 	"api-gateway/internal/database"
 	"api-gateway/internal/handlers"
+	"api-gateway/internal/mailer"
+	"api-gateway/internal/metrics"
 	"api-gateway/internal/middleware"
+	"api-gateway/internal/models"
 	"api-gateway/internal/proxy"
+	"api-gateway/internal/utils"
+	"common/logging"
+	"common/tracing"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	configFile := flag.String("config", "", "file with environment variables (default .env, if present)")
+	printConfig := flag.Bool("print-config", false, "print the configuration with secrets redacted and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout)
+		return
 	}
 
-	cfg := config.New()
+	if err := logging.Setup("gateway", cfg.LogLevel); err != nil {
+		logging.Fatal("Failed to configure logging", "error", err)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "gateway", cfg.TracesExporter)
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
 
 	db, err := database.Connect(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
+	defer db.Close()
 
 	if err := database.Migrate(db); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+		logging.Fatal("Failed to run migrations", "error", err)
 	}
+	metrics.RegisterDB(db)
 
-	authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret)
-	proxyHandler := proxy.NewProxyHandler()
+	if cfg.AdminEmail != "" {
+		if err := models.BootstrapAdmin(context.Background(), db, cfg.AdminEmail); err != nil {
+			slog.Error("Failed to bootstrap admin", "email", cfg.AdminEmail, "error", err)
+		}
+	}
+
+	keys, err := utils.LoadKeySet(cfg.JWTKeysDir, cfg.JWTSigningKID, cfg.JWTGenerateDevKey)
+	if err != nil {
+		logging.Fatal("Failed to load JWT signing keys", "error", err)
+	}
 
-	router := gin.Default()
+	authHandler := handlers.NewAuthHandler(db, cfg, keys, mailer.New(cfg.SMTPAddr, cfg.MailFrom, cfg.MailDir))
+	adminHandler := handlers.NewAdminHandler(db)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db)
+	proxyHandler, err := proxy.NewProxyHandler(cfg, keys)
+	if err != nil {
+		logging.Fatal("Failed to configure proxy", "error", err)
+	}
+	go proxyHandler.WatchRoutes(cfg.RoutesReloadInterval)
+	proxyHandler.WatchHealth(cfg.HealthCheckInterval)
+	healthHandler := handlers.NewHealthHandler(db, proxyHandler)
+
+	// Запросы и паники логируют свои middleware, стандартные gin не нужны.
+	router := gin.New()
+	// Без этого gin доверяет X-Forwarded-For от любого клиента, и подменой
+	// заголовка можно обойти ограничения входа по IP.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logging.Fatal("Failed to configure trusted proxies", "error", err)
+	}
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:  []string{"*"},
 		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:  []string{"*"},
-		ExposeHeaders: []string{"Content-Length"},
+		ExposeHeaders: []string{"Content-Length", middleware.RequestIDHeader},
 	}))
 
+	router.Use(otelgin.Middleware("gateway"))
+	router.Use(middleware.RequestID())
 	router.Use(middleware.Logger())
+	router.Use(middleware.Recovery())
+	router.Use(metrics.Middleware())
+
+	router.GET("/healthz", healthHandler.Healthz)
+	router.GET("/readyz", healthHandler.Readyz)
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	requireAuth := middleware.AuthMiddleware(keys, db)
 
 	auth := router.Group("/auth")
 	{
 		auth.POST("/register", authHandler.Register)
 		auth.POST("/login", authHandler.Login)
+		auth.POST("/login/2fa", authHandler.LoginTwoFactor)
+		auth.POST("/login/2fa/enroll", authHandler.EnrollWithChallenge)
+		auth.POST("/refresh", authHandler.Refresh)
+		auth.POST("/logout", requireAuth, authHandler.Logout)
+		auth.POST("/password/forgot", authHandler.ForgotPassword)
+		auth.POST("/password/reset", authHandler.ResetPassword)
+		auth.GET("/me", requireAuth, authHandler.GetMe)
+		auth.PUT("/me", requireAuth, authHandler.UpdateMe)
+		auth.POST("/change-password", requireAuth, authHandler.ChangePassword)
+		auth.POST("/2fa/setup", requireAuth, authHandler.SetupTwoFactor)
+		auth.POST("/2fa/enable", requireAuth, authHandler.EnableTwoFactor)
+		auth.POST("/2fa/disable", requireAuth, authHandler.DisableTwoFactor)
+		auth.POST("/2fa/recovery-codes", requireAuth, authHandler.RegenerateRecoveryCodes)
+		auth.GET("/api-keys", requireAuth, apiKeyHandler.List)
+		auth.POST("/api-keys", requireAuth, apiKeyHandler.Create)
+		auth.POST("/api-keys/revoke", requireAuth, apiKeyHandler.Revoke)
 	}
 
-	api := router.Group("/api")
-	api.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	admin := router.Group("/admin")
+	admin.Use(requireAuth, middleware.RequirePermission(models.PermRolesManage))
 	{
-		api.POST("/drone/create", proxyHandler.ProxyToDroneService)
-		api.POST("/drone/activate", proxyHandler.ProxyToDroneService)
-		api.POST("/drone/move", proxyHandler.ProxyToDroneService)
-		api.GET("/drones", proxyHandler.ProxyToDroneService)
-		api.POST("/drone/getlist", proxyHandler.ProxyToDroneService)
-		api.POST("/drone/info", proxyHandler.ProxyToDroneService)
-		api.POST("/drone/stop", proxyHandler.ProxyToDroneService)
-
-		api.POST("/requests/create", proxyHandler.ProxyToPoliceService)
-		api.POST("/requests/user", proxyHandler.ProxyToPoliceService)
-
-		policeOnly := api.Group("")
-		policeOnly.Use(middleware.PoliceOnlyMiddleware())
-		{
-			policeOnly.GET("/requests", proxyHandler.ProxyToPoliceService)
-			policeOnly.POST("/requests/update", proxyHandler.ProxyToPoliceService)
-		}
-
-		api.GET("/map", proxyHandler.ProxyToMapService)
-		api.POST("/map/create", proxyHandler.ProxyToMapService)
-		api.POST("/map/update", proxyHandler.ProxyToMapService)
+		admin.POST("/users/role", adminHandler.ChangeRole)
+		admin.POST("/invitations", adminHandler.CreateInvitation)
+		admin.GET("/roles/audit", adminHandler.GetRoleChanges)
+		admin.GET("/roles", adminHandler.GetRoles)
+		admin.POST("/roles", adminHandler.CreateRole)
+		admin.POST("/roles/permissions", adminHandler.SetRolePermissions)
+		admin.GET("/permissions", adminHandler.GetPermissions)
+		// Сводка раскрывает версии и состояние экземпляров сервисов.
+		admin.GET("/status", healthHandler.Status)
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Маршруты к сервисам описаны в cfg.RoutesFile
+	router.Any("/api/*path",
+		proxyHandler.MatchRoute,
+		proxyHandler.RouteAuth(requireAuth),
+		middleware.RateLimit(middleware.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst)),
+		proxyHandler.RoutePermission,
+		proxyHandler.Proxy,
+	)
+
+	// SIGTERM посылают оркестраторы, SIGINT - Ctrl+C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	// Метрики отдаются на отдельном адресе, а не рядом с публичным API.
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsSrv := &http.Server{Addr: cfg.MetricsAddr, Handler: metricsMux}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	go func() {
+		serveErr <- metricsSrv.ListenAndServe()
+	}()
+	slog.Info("Server starting", "port", cfg.Port, "metrics_addr", cfg.MetricsAddr)
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("Server stopped", "error", err)
+	case <-ctx.Done():
 	}
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	// Новые соединения не принимаются, начатые запросы, в том числе
+	// проксируемые, дорабатывают до ShutdownTimeout.
+	slog.Info("Shutting down", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Requests did not finish before shutdown", "error", err)
+	}
+	metricsSrv.Close()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush spans", "error", err)
+	}
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,200 @@
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// LoadFile дописывает в окружение переменные из файла в формате .env.
+// Уже заданные переменные окружения важнее файла. Файл по умолчанию может
+// отсутствовать, явно указанный - нет.
+func LoadFile(path string) error {
+	if path == "" {
+		if _, err := os.Stat(".env"); err != nil {
+			return nil
+		}
+		path = ".env"
+	}
+	if err := godotenv.Load(path); err != nil {
+		return fmt.Errorf("ошибка чтения файла конфигурации %s: %w", path, err)
+	}
+	return nil
+}
+
+type variable struct {
+	key    string
+	value  string
+	secret bool
+}
+
+// Loader читает переменные окружения, запоминает их для печати и копит
+// ошибки, чтобы при старте сообщить обо всех сразу.
+type Loader struct {
+	vars []variable
+	errs []error
+}
+
+func (l *Loader) lookup(key, defaultValue string, secret bool) string {
+	value := os.Getenv(key)
+	if value == "" {
+		value = defaultValue
+	}
+	l.vars = append(l.vars, variable{key: key, value: value, secret: secret})
+	return value
+}
+
+// Fail добавляет ошибку проверки переменной key, например для проверок,
+// связывающих несколько переменных.
+func (l *Loader) Fail(key, format string, args ...interface{}) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+// Err возвращает все накопленные ошибки разом.
+func (l *Loader) Err() error {
+	return errors.Join(l.errs...)
+}
+
+func (l *Loader) GetEnv(key, defaultValue string) string {
+	return l.lookup(key, defaultValue, false)
+}
+
+// RequireSecret - обязательная переменная без значения по умолчанию,
+// которая не печатается.
+func (l *Loader) RequireSecret(key string) string {
+	value := l.lookup(key, "", true)
+	if value == "" {
+		l.Fail(key, "обязательная переменная не задана")
+	}
+	return value
+}
+
+func (l *Loader) GetEnumEnv(key, defaultValue string, allowed ...string) string {
+	value := l.GetEnv(key, defaultValue)
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	l.Fail(key, "допустимые значения: %s", strings.Join(allowed, ", "))
+	return value
+}
+
+// GetListEnv разбирает список через запятую, пропуская пустые элементы.
+func (l *Loader) GetListEnv(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(l.GetEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// GetURLListEnv - непустой список абсолютных http(s) адресов.
+func (l *Loader) GetURLListEnv(key, defaultValue string) []string {
+	list := l.GetListEnv(key, defaultValue)
+	if len(list) == 0 {
+		l.Fail(key, "не указан ни один адрес")
+	}
+	for _, item := range list {
+		if !validURL(item) {
+			l.Fail(key, "неверный адрес %q", item)
+		}
+	}
+	return list
+}
+
+func (l *Loader) GetURLEnv(key, defaultValue string) string {
+	value := l.GetEnv(key, defaultValue)
+	if !validURL(value) {
+		l.Fail(key, "неверный адрес %q", value)
+	}
+	return value
+}
+
+// GetDurationEnv - положительная длительность в формате time.ParseDuration.
+func (l *Loader) GetDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := l.GetEnv(key, defaultValue.String())
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		l.Fail(key, "ожидается положительная длительность, например 30s, получено %q", value)
+		return defaultValue
+	}
+	return d
+}
+
+// GetIntEnv - целое число не меньше min.
+func (l *Loader) GetIntEnv(key string, defaultValue, min int) int {
+	value := l.GetEnv(key, strconv.Itoa(defaultValue))
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		l.Fail(key, "ожидается целое число не меньше %d, получено %q", min, value)
+		return defaultValue
+	}
+	return n
+}
+
+// GetBoolEnv - логическое значение (true/false, 1/0).
+func (l *Loader) GetBoolEnv(key string, defaultValue bool) bool {
+	value := l.GetEnv(key, strconv.FormatBool(defaultValue))
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.Fail(key, "ожидается true или false, получено %q", value)
+		return defaultValue
+	}
+	return b
+}
+
+// GetFloatEnv - положительное число.
+func (l *Loader) GetFloatEnv(key string, defaultValue float64) float64 {
+	value := l.GetEnv(key, strconv.FormatFloat(defaultValue, 'f', -1, 64))
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		l.Fail(key, "ожидается положительное число, получено %q", value)
+		return defaultValue
+	}
+	return f
+}
+
+// GetPortEnv - номер TCP порта.
+func (l *Loader) GetPortEnv(key, defaultValue string) string {
+	value := l.GetEnv(key, defaultValue)
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		l.Fail(key, "неверный номер порта %q", value)
+	}
+	return value
+}
+
+func validURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Print печатает прочитанные переменные в формате .env. Вместо секретов
+// выводится маска, у адресов маскируется только пароль.
+func (l *Loader) Print(w io.Writer) {
+	for _, v := range l.vars {
+		value := v.value
+		if v.secret && value != "" {
+			value = redact(value)
+		}
+		fmt.Fprintf(w, "%s=%s\n", v.key, value)
+	}
+}
+
+func redact(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil && !strings.Contains(u.RawQuery, "password") {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			return u.Redacted()
+		}
+	}
+	return "********"
+}
@@ -0,0 +1,277 @@
+package gatewayauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"common/logging"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Header и Audience - заголовок и audience токена, которым шлюз передает
+// сервисам личность пользователя.
+const (
+	Header   = "X-Gateway-Identity"
+	Audience = "internal-services"
+)
+
+const (
+	// refetchInterval ограничивает повторную загрузку JWKS при неизвестном kid.
+	refetchInterval = 30 * time.Second
+	clockSkew       = 5 * time.Second
+)
+
+// Identity - личность пользователя, проверенная шлюзом. Токен привязан к
+// конкретному запросу (метод, путь, длина и тип тела), поэтому перехваченный
+// токен нельзя использовать для другого запроса. Хеш тела не подписывается:
+// тело идет потоком и шлюз не читает его целиком.
+type Identity struct {
+	UserID      int      `json:"user_id"`
+	RoleID      int      `json:"role_id"`
+	Permissions []string `json:"permissions"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	// ContentLength -1 - тело передается без известной длины (chunked).
+	ContentLength int64  `json:"content_length"`
+	ContentType   string `json:"content_type"`
+	jwt.RegisteredClaims
+}
+
+// ErrorWriter отвечает клиенту ошибкой в формате API сервиса.
+type ErrorWriter func(w http.ResponseWriter, status int, message string)
+
+// Verifier принимает только запросы, пришедшие через шлюз: проверяет
+// подпись заголовка Header по открытым ключам шлюза (JWKS) и привязку
+// токена к методу, пути и телу запроса.
+type Verifier struct {
+	jwksURL    string
+	client     *http.Client
+	parser     *jwt.Parser
+	writeError ErrorWriter
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	lastFetch time.Time
+	// fetching закрывается по окончании текущей загрузки JWKS, nil - загрузки
+	// нет. Загрузка идет без блокировки, чтобы проверка токенов с известными
+	// ключами ее не ждала.
+	fetching chan struct{}
+}
+
+// NewVerifier создает проверку с ключами из jwksURL. Если writeError не
+// задан, ошибки отдаются текстом через http.Error.
+func NewVerifier(jwksURL string, writeError ErrorWriter) *Verifier {
+	if writeError == nil {
+		writeError = func(w http.ResponseWriter, status int, message string) {
+			http.Error(w, message, status)
+		}
+	}
+	return &Verifier{
+		jwksURL: jwksURL,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+			jwt.WithAudience(Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(clockSkew),
+		),
+		writeError: writeError,
+		keys:       make(map[string]crypto.PublicKey),
+	}
+}
+
+type contextKey struct{}
+
+// FromContext возвращает личность, проверенную Middleware. Обработчики берут
+// пользователя только отсюда, а не из тела запроса.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok
+}
+
+// Middleware проверяет личность и кладет ее в контекст запроса. Тело не
+// читается и передается обработчику потоком.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := v.Verify(r.Header.Get(Header), r.Method, r.URL.Path)
+		if err != nil {
+			// Причина нужна для разбора, но клиенту ее не показывают.
+			slog.WarnContext(r.Context(), "Запрос не прошел проверку шлюза", "method", r.Method, "path", r.URL.Path, "error", err)
+			v.writeError(w, http.StatusUnauthorized, "Запрос не прошел проверку шлюза")
+			return
+		}
+
+		// Сервер читает ровно Content-Length байт, так что другое тело той же
+		// длины - единственная возможная подмена, и только в пределах срока
+		// жизни токена.
+		if r.ContentLength != identity.ContentLength || r.Header.Get("Content-Type") != identity.ContentType {
+			slog.WarnContext(r.Context(), "Запрос не прошел проверку шлюза", "method", r.Method, "path", r.URL.Path, "error", "тело запроса изменено")
+			v.writeError(w, http.StatusUnauthorized, "Запрос не прошел проверку шлюза")
+			return
+		}
+
+		r.Header.Del(Header)
+		ctx := logging.With(context.WithValue(r.Context(), contextKey{}, identity), "user_id", identity.UserID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Verify проверяет подпись, audience и срок действия токена и его привязку
+// к методу и пути. Хеш тела сверяет Middleware.
+func (v *Verifier) Verify(token, method, path string) (*Identity, error) {
+	if token == "" {
+		return nil, errors.New("нет заголовка " + Header)
+	}
+
+	var identity Identity
+	if _, err := v.parser.ParseWithClaims(token, &identity, v.keyFunc); err != nil {
+		return nil, describe(err)
+	}
+	if identity.Method != method || identity.Path != path {
+		return nil, errors.New("токен выпущен для другого запроса")
+	}
+
+	return &identity, nil
+}
+
+// keyError - ошибка поиска ключа, ее текст понятнее общей ошибки jwt.
+type keyError string
+
+func (e keyError) Error() string {
+	return string(e)
+}
+
+// describe переводит ошибку разбора токена в сообщение для ответа.
+func describe(err error) error {
+	var keyErr keyError
+	switch {
+	case errors.As(err, &keyErr):
+		return keyErr
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return errors.New("неверный формат токена")
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return errors.New("неверная подпись")
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return errors.New("токен не предназначен для сервисов")
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return errors.New("срок действия токена истек")
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return errors.New("в токене не указан срок действия")
+	}
+	return errors.New("токен не прошел проверку")
+}
+
+// keyFunc возвращает ключ по kid из заголовка токена. Соответствие
+// алгоритма типу ключа проверяет jwt.
+func (v *Verifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	return v.key(kid)
+}
+
+// key возвращает ключ по kid, при необходимости перечитывая JWKS: после
+// ротации шлюз начинает подписывать новым ключом. JWKS загружается не чаще
+// раза в refetchInterval, сколько бы неизвестных kid ни приходило, и
+// одновременные запросы ждут одну загрузку.
+func (v *Verifier) key(kid string) (crypto.PublicKey, error) {
+	for {
+		v.mu.Lock()
+		if key, ok := v.keys[kid]; ok {
+			v.mu.Unlock()
+			return key, nil
+		}
+
+		if fetching := v.fetching; fetching != nil {
+			v.mu.Unlock()
+			<-fetching
+			continue
+		}
+
+		if time.Since(v.lastFetch) < refetchInterval {
+			v.mu.Unlock()
+			return nil, keyError(fmt.Sprintf("неизвестный ключ %q", kid))
+		}
+		v.lastFetch = time.Now()
+		fetching := make(chan struct{})
+		v.fetching = fetching
+		v.mu.Unlock()
+
+		keys, err := v.fetch()
+
+		v.mu.Lock()
+		if err == nil {
+			v.keys = keys
+		}
+		v.fetching = nil
+		close(fetching)
+		v.mu.Unlock()
+
+		if err != nil {
+			return nil, keyError(fmt.Sprintf("ошибка загрузки JWKS: %v", err))
+		}
+	}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// fetch загружает JWKS шлюза. Ключи неизвестных типов пропускаются.
+func (v *Verifier) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := v.client.Get(v.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		switch {
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			keys[k.Kid] = ed25519.PublicKey(x)
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,314 @@
+package gatewayauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testKID = "test-key"
+
+type testGateway struct {
+	key      ed25519.PrivateKey
+	verifier *Verifier
+	// fetches - число загрузок JWKS.
+	fetches atomic.Int32
+	// Пока hold включен, JWKS не отвечает до закрытия release.
+	hold    atomic.Bool
+	release chan struct{}
+}
+
+// newTestGateway поднимает JWKS с одним Ed25519 ключом и Verifier к нему.
+func newTestGateway(t *testing.T) *testGateway {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw := &testGateway{key: priv, release: make(chan struct{})}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gw.fetches.Add(1)
+		if gw.hold.Load() {
+			<-gw.release
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"kid": testKID,
+				"x":   base64.RawURLEncoding.EncodeToString(pub),
+			}},
+		})
+	}))
+	t.Cleanup(jwks.Close)
+
+	gw.verifier = NewVerifier(jwks.URL, nil)
+	return gw
+}
+
+const jsonType = "application/json"
+
+func validIdentity(method, path, body string) Identity {
+	return Identity{
+		UserID:        7,
+		RoleID:        2,
+		Permissions:   []string{"drones:force_stop"},
+		Method:        method,
+		Path:          path,
+		ContentLength: int64(len(body)),
+		ContentType:   jsonType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{Audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * time.Second)),
+		},
+	}
+}
+
+func sign(t *testing.T, key ed25519.PrivateKey, identity Identity) string {
+	t.Helper()
+	return signWithKID(t, key, testKID, identity)
+}
+
+func signWithKID(t *testing.T, key ed25519.PrivateKey, kid string, identity Identity) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, identity)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	gw := newTestGateway(t)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     ed25519.PrivateKey
+		modify  func(*Identity)
+		method  string
+		path    string
+		wantErr string
+	}{
+		{name: "valid", method: http.MethodPost, path: "/api/drone/stop"},
+		{name: "bad signature", key: otherKey, method: http.MethodPost, path: "/api/drone/stop", wantErr: "неверная подпись"},
+		{
+			name:    "wrong audience",
+			modify:  func(i *Identity) { i.Audience = jwt.ClaimStrings{"gateway"} },
+			method:  http.MethodPost,
+			path:    "/api/drone/stop",
+			wantErr: "токен не предназначен для сервисов",
+		},
+		{
+			name:    "expired",
+			modify:  func(i *Identity) { i.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) },
+			method:  http.MethodPost,
+			path:    "/api/drone/stop",
+			wantErr: "срок действия токена истек",
+		},
+		{
+			name:    "missing expiry",
+			modify:  func(i *Identity) { i.ExpiresAt = nil },
+			method:  http.MethodPost,
+			path:    "/api/drone/stop",
+			wantErr: "в токене не указан срок действия",
+		},
+		{name: "method mismatch", method: http.MethodGet, path: "/api/drone/stop", wantErr: "токен выпущен для другого запроса"},
+		{name: "path mismatch", method: http.MethodPost, path: "/api/drone/police/stop-area", wantErr: "токен выпущен для другого запроса"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := validIdentity(http.MethodPost, "/api/drone/stop", "")
+			if tt.modify != nil {
+				tt.modify(&identity)
+			}
+			key := gw.key
+			if tt.key != nil {
+				key = tt.key
+			}
+
+			got, err := gw.verifier.Verify(sign(t, key, identity), tt.method, tt.path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.UserID != identity.UserID {
+					t.Fatalf("UserID = %d, want %d", got.UserID, identity.UserID)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsMissingAndMalformedTokens(t *testing.T) {
+	gw := newTestGateway(t)
+
+	if _, err := gw.verifier.Verify("", http.MethodPost, "/api/drone/stop"); err == nil {
+		t.Fatal("empty token accepted")
+	}
+	if _, err := gw.verifier.Verify("not.a.token", http.MethodPost, "/api/drone/stop"); err == nil || err.Error() != "неверный формат токена" {
+		t.Fatalf("error = %v, want malformed token", err)
+	}
+}
+
+func TestVerifyRejectsUnsignedToken(t *testing.T) {
+	gw := newTestGateway(t)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodNone, validIdentity(http.MethodPost, "/api/drone/stop", ""))
+	token.Header["kid"] = testKID
+	signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gw.verifier.Verify(signed, http.MethodPost, "/api/drone/stop"); err == nil {
+		t.Fatal("token with alg none accepted")
+	}
+}
+
+func TestVerifyLimitsJWKSRefetch(t *testing.T) {
+	gw := newTestGateway(t)
+	identity := validIdentity(http.MethodPost, "/api/drone/stop", "")
+
+	if _, err := gw.verifier.Verify(sign(t, gw.key, identity), http.MethodPost, "/api/drone/stop"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Неизвестные kid не должны перечитывать JWKS на каждый запрос.
+	for i := 0; i < 10; i++ {
+		token := signWithKID(t, gw.key, fmt.Sprintf("bogus-%d", i), identity)
+		if _, err := gw.verifier.Verify(token, http.MethodPost, "/api/drone/stop"); err == nil {
+			t.Fatal("token with unknown kid accepted")
+		}
+	}
+
+	if got := gw.fetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", got)
+	}
+}
+
+func TestVerifyDoesNotWaitForJWKSFetch(t *testing.T) {
+	gw := newTestGateway(t)
+	identity := validIdentity(http.MethodPost, "/api/drone/stop", "")
+	if _, err := gw.verifier.Verify(sign(t, gw.key, identity), http.MethodPost, "/api/drone/stop"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Следующая загрузка JWKS зависает, пока тест ее не отпустит.
+	gw.hold.Store(true)
+	gw.verifier.mu.Lock()
+	gw.verifier.lastFetch = time.Time{}
+	gw.verifier.mu.Unlock()
+
+	fetched := make(chan struct{})
+	go func() {
+		defer close(fetched)
+		gw.verifier.Verify(signWithKID(t, gw.key, "rotated", identity), http.MethodPost, "/api/drone/stop")
+	}()
+	for gw.fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	verified := make(chan error, 1)
+	go func() {
+		_, err := gw.verifier.Verify(sign(t, gw.key, identity), http.MethodPost, "/api/drone/stop")
+		verified <- err
+	}()
+
+	select {
+	case err := <-verified:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("token with known key waited for JWKS fetch")
+	}
+
+	close(gw.release)
+	<-fetched
+}
+
+func TestMiddleware(t *testing.T) {
+	gw := newTestGateway(t)
+
+	var gotBody string
+	var gotIdentity *Identity
+	handler := gw.verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotIdentity, _ = FromContext(r.Context())
+		if r.Header.Get(Header) != "" {
+			t.Error("identity header passed to handler")
+		}
+	}))
+
+	const path = "/api/drone/stop"
+	const signedBody = `{"drone_id":5}`
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		header      bool
+		wantStatus  int
+	}{
+		{name: "valid", body: signedBody, contentType: jsonType, header: true, wantStatus: http.StatusOK},
+		{name: "body length mismatch", body: `{"drone_id":55}`, contentType: jsonType, header: true, wantStatus: http.StatusUnauthorized},
+		{name: "content type mismatch", body: signedBody, contentType: "text/plain", header: true, wantStatus: http.StatusUnauthorized},
+		{name: "no identity header", body: signedBody, contentType: jsonType, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBody, gotIdentity = "", nil
+
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.header {
+				req.Header.Set(Header, sign(t, gw.key, validIdentity(http.MethodPost, path, signedBody)))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if gotIdentity != nil {
+					t.Fatal("handler called for rejected request")
+				}
+				return
+			}
+			if gotBody != tt.body {
+				t.Fatalf("body = %q, want %q", gotBody, tt.body)
+			}
+			if gotIdentity == nil || gotIdentity.UserID != 7 {
+				t.Fatalf("identity = %+v, want user 7", gotIdentity)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Version задается при сборке:
+// go build -ldflags "-X common/health.Version=1.2.3"
+var Version = "dev"
+
+const checkTimeout = 2 * time.Second
+
+// Check проверяет одну зависимость сервиса.
+type Check func(ctx context.Context) error
+
+type Report struct {
+	Status  string            `json:"status"`
+	Service string            `json:"service"`
+	Version string            `json:"version"`
+	Uptime  string            `json:"uptime"`
+	Checks  map[string]string `json:"checks,omitempty"`
+}
+
+// Handler отвечает на /healthz (процесс жив) и /readyz (зависимости
+// доступны и сервис готов принимать запросы).
+type Handler struct {
+	service string
+	started time.Time
+	checks  map[string]Check
+}
+
+func New(service string) *Handler {
+	return &Handler{service: service, started: time.Now(), checks: make(map[string]Check)}
+}
+
+func (h *Handler) AddCheck(name string, check Check) {
+	h.checks[name] = check
+}
+
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, h.report("ok"))
+}
+
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	report := h.report("ready")
+	report.Checks = make(map[string]string, len(h.checks))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			// Текст ошибки может раскрыть адреса зависимостей, он только в логе.
+			result := "ok"
+			if err := check(ctx); err != nil {
+				slog.ErrorContext(ctx, "Проверка готовности не прошла", "check", name, "error", err)
+				result = "error"
+			}
+
+			mu.Lock()
+			report.Checks[name] = result
+			if result != "ok" {
+				report.Status = "not_ready"
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if report.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+func (h *Handler) report(status string) Report {
+	return Report{
+		Status:  status,
+		Service: h.service,
+		Version: Version,
+		Uptime:  time.Since(h.started).Round(time.Second).String(),
+	}
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
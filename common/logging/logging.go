@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey struct{}
+
+// Setup делает логгером по умолчанию JSON логгер в stdout с уровнем level
+// (debug, info, warn, error). Стандартный log после этого тоже пишет через
+// него.
+func Setup(service, level string) error {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("неверный уровень логирования %q", level)
+	}
+
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: minLevel})
+	slog.SetDefault(slog.New(contextHandler{handler}).With("service", service))
+	return nil
+}
+
+// Fatal пишет ошибку и завершает процесс.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// With добавляет поля ко всем записям, сделанным с возвращенным контекстом:
+// так в логи обработчиков попадают request_id, user_id и drone_id. Поле с
+// уже заданным ключом заменяется.
+func With(ctx context.Context, args ...any) context.Context {
+	var record slog.Record
+	record.Add(args...)
+
+	added := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		added = append(added, attr)
+		return true
+	})
+
+	attrs := make([]slog.Attr, 0, len(fields(ctx))+len(added))
+	for _, attr := range fields(ctx) {
+		if !hasKey(added, attr.Key) {
+			attrs = append(attrs, attr)
+		}
+	}
+	return context.WithValue(ctx, contextKey{}, append(attrs, added...))
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Detach возвращает контекст без отмены и трассировки запроса, но с его
+// полями для логов - для работы, которая переживает запрос.
+func Detach(ctx context.Context) context.Context {
+	return context.WithValue(context.Background(), contextKey{}, fields(ctx))
+}
+
+func fields(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler дописывает в запись поля из контекста и trace_id.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(fields(ctx)...)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -1,46 +1,89 @@
 package app
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"database/sql"
+	"log/slog"
 	"net/http"
 
+	"common/gatewayauth"
+	"common/health"
+	"common/logging"
+	"common/metrics"
+	"common/requestid"
+	"common/tracing"
 	"drones-api/internal/config"
 	"drones-api/internal/database"
 	"drones-api/internal/handlers"
 	"drones-api/internal/service"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
 type App struct {
 	config       *config.Config
 	droneService *service.DroneService
 	handlers     *handlers.DroneHandlers
+	health       *health.Handler
+	db           *sql.DB
+	// shutdownTracing досылает накопленные спаны.
+	shutdownTracing func(context.Context) error
 }
 
-func NewApp() *App {
-	cfg := config.New()
+func NewApp(cfg *config.Config) *App {
+	if err := logging.Setup("drones", cfg.LogLevel); err != nil {
+		logging.Fatal("Ошибка настройки логирования", "error", err)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "drones", cfg.TracesExporter)
+	if err != nil {
+		logging.Fatal("Ошибка настройки трассировки", "error", err)
+	}
 
 	db, err := database.NewConnection(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatal("Ошибка подключения к базе данных:", err)
+		logging.Fatal("Ошибка подключения к базе данных", "error", err)
+	}
+
+	if err := database.Migrate(db); err != nil {
+		logging.Fatal("Ошибка миграции базы данных", "error", err)
 	}
 
 	droneService := service.NewDroneService(db)
 	droneHandlers := handlers.NewDroneHandlers(droneService)
 
+	metrics.RegisterDB(db, "drones")
+	metrics.RegisterGauge("drones_moving", "Число дронов в полете, которые симулирует процесс.", func() float64 {
+		return float64(droneService.MovingCount())
+	})
+
+	healthHandler := health.New("drones")
+	healthHandler.AddCheck("database", db.PingContext)
+
 	return &App{
 		config:       cfg,
 		droneService: droneService,
 		handlers:     droneHandlers,
+		health:       healthHandler,
+		db:           db,
+
+		shutdownTracing: shutdownTracing,
 	}
 }
 
-func (a *App) Run() error {
+// Run обслуживает запросы до отмены ctx, затем останавливается: дожидается
+// начатых запросов, сохраняет дроны в полете, досылает спаны и закрывает БД.
+func (a *App) Run(ctx context.Context) error {
 	r := mux.NewRouter()
+	r.Use(otelmux.Middleware("drones"), requestid.Middleware, metrics.Middleware)
+
+	r.HandleFunc("/healthz", a.health.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", a.health.Readyz).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	api := r.PathPrefix("/api").Subrouter()
+	api.Use(gatewayauth.NewVerifier(a.config.GatewayJWKSURL, handlers.WriteError).Middleware)
 
 	api.HandleFunc("/drone/create", a.handlers.CreateDrone).Methods("POST")
 	api.HandleFunc("/drone/activate", a.handlers.ActivateDrone).Methods("POST")
@@ -49,7 +92,51 @@ func (a *App) Run() error {
 	api.HandleFunc("/drone/getlist", a.handlers.GetUserDrones).Methods("POST")
 	api.HandleFunc("/drone/info", a.handlers.GetDroneInfo).Methods("POST")
 	api.HandleFunc("/drone/stop", a.handlers.StopDrone).Methods("POST")
+	api.HandleFunc("/drone/decommission", a.handlers.DecommissionDrone).Methods("POST")
+	api.HandleFunc("/drone/lockdown", a.handlers.Lockdown).Methods("POST")
+	api.HandleFunc("/drone/police/stop-area", a.handlers.PoliceStopArea).Methods("POST")
+	api.HandleFunc("/drone/police/stop-user", a.handlers.PoliceStopUser).Methods("POST")
+	api.HandleFunc("/drone/police/force-land", a.handlers.ForceLand).Methods("POST")
+	api.HandleFunc("/drone/police/lock", a.handlers.LockDrone).Methods("POST")
+	api.HandleFunc("/drone/police/unlock", a.handlers.UnlockDrone).Methods("POST")
+	api.HandleFunc("/org/create", a.handlers.CreateOrganization).Methods("POST")
+	api.HandleFunc("/org/list", a.handlers.GetUserOrganizations).Methods("POST")
+	api.HandleFunc("/org/members", a.handlers.GetOrganizationMembers).Methods("POST")
+	api.HandleFunc("/org/members/add", a.handlers.AddOrganizationMember).Methods("POST")
+	api.HandleFunc("/org/members/remove", a.handlers.RemoveOrganizationMember).Methods("POST")
+	api.HandleFunc("/org/drones/transfer", a.handlers.TransferDrone).Methods("POST")
+
+	srv := &http.Server{Addr: ":" + a.config.Port, Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	slog.Info("Сервер запущен", "port", a.config.Port)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Остановка сервера", "timeout", a.config.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Не все запросы завершились до остановки", "error", err)
+	}
+	// Полеты останавливаются после запросов: начатый запрос мог запустить новый.
+	if err := a.droneService.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Не все дроны сохранены до остановки", "error", err)
+	}
+	if err := a.shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Ошибка отправки спанов", "error", err)
+	}
+	if err := a.db.Close(); err != nil {
+		return err
+	}
 
-	fmt.Printf("Сервер запущен на порту %s\n", a.config.Port)
-	return http.ListenAndServe(":"+a.config.Port, r)
+	slog.Info("Сервер остановлен")
+	return nil
 }
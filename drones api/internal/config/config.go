@@ -1,25 +1,53 @@
 package config
 
-import "os"
+import (
+	"common/envconfig"
+	"io"
+	"time"
+)
 
 type Config struct {
 	Port        string
 	DatabaseURL string
+	// GatewayJWKSURL - открытые ключи шлюза для проверки подписи запросов.
+	GatewayJWKSURL string
+	// TracesExporter - куда отправлять спаны: otlp, stdout или none.
+	TracesExporter string
+	// LogLevel - минимальный уровень логов: debug, info, warn или error.
+	LogLevel string
+	// ShutdownTimeout ограничивает ожидание запросов и сохранения полетов
+	// при остановке.
+	ShutdownTimeout time.Duration
+
+	// env - прочитанные переменные для --print-config.
+	env *envconfig.Loader
 }
 
-func New() *Config {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8083"
+// Load читает конфигурацию из окружения и необязательного файла path
+// (по умолчанию .env) и проверяет ее. Ошибки возвращаются все сразу.
+func Load(path string) (*Config, error) {
+	if err := envconfig.LoadFile(path); err != nil {
+		return nil, err
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		dbURL = "host=aws-0-us-east-2.pooler.supabase.com port=5432 user=postgres.akodbsqofninasbpqxbx password=HgO3lFR752WPNGN dbname=postgres sslmode=require"
+	l := &envconfig.Loader{}
+	cfg := &Config{
+		Port:            l.GetPortEnv("PORT", "8083"),
+		DatabaseURL:     l.RequireSecret("DATABASE_URL"),
+		GatewayJWKSURL:  l.GetURLEnv("GATEWAY_JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
+		TracesExporter:  l.GetEnumEnv("OTEL_TRACES_EXPORTER", "none", "otlp", "stdout", "none"),
+		LogLevel:        l.GetEnumEnv("LOG_LEVEL", "info", "debug", "info", "warn", "error"),
+		ShutdownTimeout: l.GetDurationEnv("SHUTDOWN_TIMEOUT", 15*time.Second),
 	}
 
-	return &Config{
-		Port:        port,
-		DatabaseURL: dbURL,
+	cfg.env = l
+	if err := l.Err(); err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
+
+// Print выводит итоговую конфигурацию в формате .env без секретов.
+func (c *Config) Print(w io.Writer) {
+	c.env.Print(w)
 }
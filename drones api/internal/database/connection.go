@@ -3,12 +3,15 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
+
+	"common/tracing"
 
 	_ "github.com/lib/pq"
 )
 
 func NewConnection(connStr string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", connStr)
+	db, err := tracing.OpenDB("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка открытия соединения с БД: %w", err)
 	}
@@ -17,6 +20,6 @@ func NewConnection(connStr string) (*sql.DB, error) {
 		return nil, fmt.Errorf("ошибка проверки соединения с БД: %w", err)
 	}
 
-	fmt.Println("Подключение к базе данных успешно установлено")
+	slog.Info("Подключение к базе данных успешно установлено")
 	return db, nil
 }
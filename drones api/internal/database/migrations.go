@@ -0,0 +1,71 @@
+package database
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	createDroneLocksTable := `
+	CREATE TABLE IF NOT EXISTS drone_locks (
+		drone_id INTEGER PRIMARY KEY,
+		locked_by INTEGER NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createDroneLocksTable); err != nil {
+		return err
+	}
+
+	createOrganizationsTables := `
+	CREATE TABLE IF NOT EXISTS organizations (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		created_by INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS organization_members (
+		organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL,
+		role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'pilot', 'viewer')),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (organization_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+	ALTER TABLE drones ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;`
+
+	if _, err := db.Exec(createOrganizationsTables); err != nil {
+		return err
+	}
+
+	// drones.flight_id - текущий полет дрона. Полеты симулируют разные
+	// экземпляры сервиса, и каждый сверяет flight_id на каждом тике: так
+	// полет останавливается с любого экземпляра.
+	createDroneFlightsTable := `
+	CREATE TABLE IF NOT EXISTS drone_flights (
+		id BIGSERIAL PRIMARY KEY,
+		drone_id INTEGER NOT NULL,
+		start_lat DOUBLE PRECISION NOT NULL,
+		start_lng DOUBLE PRECISION NOT NULL,
+		start_altitude DOUBLE PRECISION NOT NULL,
+		target_lat DOUBLE PRECISION NOT NULL,
+		target_lng DOUBLE PRECISION NOT NULL,
+		target_altitude DOUBLE PRECISION NOT NULL,
+		speed DOUBLE PRECISION NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_drone_flights_drone_id ON drone_flights(drone_id);
+	ALTER TABLE drones ADD COLUMN IF NOT EXISTS flight_id BIGINT;`
+
+	if _, err := db.Exec(createDroneFlightsTable); err != nil {
+		return err
+	}
+
+	// decommissioned_at - время вывода дрона из эксплуатации. Police api
+	// проверяет его при подаче заявок на полет.
+	addDecommissionedAt := `ALTER TABLE drones ADD COLUMN IF NOT EXISTS decommissioned_at TIMESTAMP;`
+
+	if _, err := db.Exec(addDecommissionedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
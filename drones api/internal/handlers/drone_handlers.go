@@ -3,8 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 
+	"common/logging"
+	"common/requestid"
 	"drones-api/internal/models"
 	"drones-api/internal/service"
 )
@@ -21,15 +24,22 @@ func NewDroneHandlers(droneService *service.DroneService) *DroneHandlers {
 
 func (h *DroneHandlers) CreateDrone(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateDroneRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
 		return
 	}
 
-	drone, err := h.droneService.CreateDrone(req)
+	drone, err := h.droneService.CreateDrone(r.Context(), req)
 	if err != nil {
-		fmt.Printf("Ошибка создания дрона: %v\n", err)
-		h.sendResponse(w, false, "Ошибка создания дрона", nil, http.StatusInternalServerError)
+		switch err {
+		case service.ErrAccessDenied:
+			h.sendResponse(w, false, "Создавать дроны организации может только ее владелец", nil, http.StatusForbidden)
+			return
+		case service.ErrOrgNotFound:
+			h.sendResponse(w, false, "Организация не найдена", nil, http.StatusNotFound)
+			return
+		}
+		h.sendServerError(w, r, "Ошибка создания дрона", err)
 		return
 	}
 
@@ -38,18 +48,26 @@ func (h *DroneHandlers) CreateDrone(w http.ResponseWriter, r *http.Request) {
 
 func (h *DroneHandlers) ActivateDrone(w http.ResponseWriter, r *http.Request) {
 	var req models.ActivateDroneRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
 		return
 	}
+	r = r.WithContext(logging.With(r.Context(), "drone_id", req.DroneID))
 
-	err := h.droneService.ActivateDrone(req)
+	err := h.droneService.ActivateDrone(r.Context(), req)
 	if err != nil {
-		if err == service.ErrAccessDenied {
+		switch err {
+		case service.ErrAccessDenied:
 			h.sendResponse(w, false, "Доступ запрещен", nil, http.StatusForbidden)
-			return
+		case service.ErrDroneNotFound:
+			h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
+		case service.ErrDroneLocked:
+			h.sendResponse(w, false, "Дрон заблокирован полицией", nil, http.StatusLocked)
+		case service.ErrDroneDecommissioned:
+			h.sendResponse(w, false, "Дрон выведен из эксплуатации", nil, http.StatusConflict)
+		default:
+			h.sendServerError(w, r, "Ошибка активации дрона", err)
 		}
-		h.sendResponse(w, false, "Ошибка активации дрона", nil, http.StatusInternalServerError)
 		return
 	}
 
@@ -58,20 +76,27 @@ func (h *DroneHandlers) ActivateDrone(w http.ResponseWriter, r *http.Request) {
 
 func (h *DroneHandlers) MoveDrone(w http.ResponseWriter, r *http.Request) {
 	var req models.MoveDroneRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
 		return
 	}
+	r = r.WithContext(logging.With(r.Context(), "drone_id", req.DroneID))
 
-	err := h.droneService.MoveDrone(req)
+	err := h.droneService.MoveDrone(r.Context(), req)
 	if err != nil {
 		switch err {
 		case service.ErrAccessDenied:
 			h.sendResponse(w, false, "Доступ запрещен", nil, http.StatusForbidden)
+		case service.ErrDroneNotFound:
+			h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
 		case service.ErrDroneNotActivated:
 			h.sendResponse(w, false, "Дрон не активирован", nil, http.StatusBadRequest)
+		case service.ErrDroneLocked:
+			h.sendResponse(w, false, "Дрон заблокирован полицией", nil, http.StatusLocked)
+		case service.ErrDroneDecommissioned:
+			h.sendResponse(w, false, "Дрон выведен из эксплуатации", nil, http.StatusConflict)
 		default:
-			h.sendResponse(w, false, "Ошибка запуска движения дрона", nil, http.StatusInternalServerError)
+			h.sendServerError(w, r, "Ошибка запуска движения дрона", err)
 		}
 		return
 	}
@@ -80,9 +105,9 @@ func (h *DroneHandlers) MoveDrone(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DroneHandlers) GetActiveDrones(w http.ResponseWriter, r *http.Request) {
-	drones, err := h.droneService.GetActiveDrones()
+	drones, err := h.droneService.GetActiveDrones(r.Context())
 	if err != nil {
-		h.sendResponse(w, false, "Ошибка получения дронов", nil, http.StatusInternalServerError)
+		h.sendServerError(w, r, "Ошибка получения дронов", err)
 		return
 	}
 
@@ -91,14 +116,14 @@ func (h *DroneHandlers) GetActiveDrones(w http.ResponseWriter, r *http.Request)
 
 func (h *DroneHandlers) GetUserDrones(w http.ResponseWriter, r *http.Request) {
 	var req models.GetUserDronesRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
 		return
 	}
 
-	drones, err := h.droneService.GetUserDrones(req)
+	drones, err := h.droneService.GetUserDrones(r.Context(), req)
 	if err != nil {
-		h.sendResponse(w, false, "Ошибка получения дронов пользователя", nil, http.StatusInternalServerError)
+		h.sendServerError(w, r, "Ошибка получения дронов пользователя", err)
 		return
 	}
 
@@ -107,18 +132,22 @@ func (h *DroneHandlers) GetUserDrones(w http.ResponseWriter, r *http.Request) {
 
 func (h *DroneHandlers) GetDroneInfo(w http.ResponseWriter, r *http.Request) {
 	var req models.DroneInfoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
 		return
 	}
+	r = r.WithContext(logging.With(r.Context(), "drone_id", req.DroneID))
 
-	drone, err := h.droneService.GetDroneInfo(req)
+	drone, err := h.droneService.GetDroneInfo(r.Context(), req)
 	if err != nil {
-		if err == service.ErrAccessDenied {
+		switch err {
+		case service.ErrAccessDenied:
 			h.sendResponse(w, false, "Доступ запрещен", nil, http.StatusForbidden)
-			return
+		case service.ErrDroneNotFound:
+			h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
+		default:
+			h.sendServerError(w, r, "Ошибка получения информации о дроне", err)
 		}
-		h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
 		return
 	}
 
@@ -127,24 +156,176 @@ func (h *DroneHandlers) GetDroneInfo(w http.ResponseWriter, r *http.Request) {
 
 func (h *DroneHandlers) StopDrone(w http.ResponseWriter, r *http.Request) {
 	var req models.StopDroneRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
 		return
 	}
+	r = r.WithContext(logging.With(r.Context(), "drone_id", req.DroneID))
 
-	err := h.droneService.StopDrone(req)
+	err := h.droneService.StopDrone(r.Context(), req)
 	if err != nil {
-		if err == service.ErrAccessDenied {
+		switch err {
+		case service.ErrAccessDenied:
 			h.sendResponse(w, false, "Доступ запрещен", nil, http.StatusForbidden)
-			return
+		case service.ErrDroneLocked:
+			h.sendResponse(w, false, "Дрон заблокирован полицией", nil, http.StatusLocked)
+		case service.ErrDroneNotFound:
+			h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
+		default:
+			h.sendServerError(w, r, "Ошибка остановки дрона", err)
 		}
-		h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
 		return
 	}
 
 	h.sendResponse(w, true, "Дрон остановлен", nil, http.StatusOK)
 }
 
+func (h *DroneHandlers) DecommissionDrone(w http.ResponseWriter, r *http.Request) {
+	var req models.DecommissionDroneRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+	r = r.WithContext(logging.With(r.Context(), "drone_id", req.DroneID))
+
+	err := h.droneService.DecommissionDrone(r.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrAccessDenied:
+			h.sendResponse(w, false, "Вывести дрон из эксплуатации может только владелец", nil, http.StatusForbidden)
+		case service.ErrDroneDecommissioned:
+			h.sendResponse(w, false, "Дрон уже выведен из эксплуатации", nil, http.StatusConflict)
+		case service.ErrDroneNotFound:
+			h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
+		default:
+			h.sendServerError(w, r, "Ошибка вывода дрона из эксплуатации", err)
+		}
+		return
+	}
+
+	h.sendResponse(w, true, "Дрон выведен из эксплуатации", nil, http.StatusOK)
+}
+
+func (h *DroneHandlers) Lockdown(w http.ResponseWriter, r *http.Request) {
+	var req models.LockdownRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.droneService.Lockdown(r.Context(), req)
+	if err != nil {
+		switch err {
+		case service.ErrAccessDenied:
+			h.sendResponse(w, false, "Доступ запрещен", nil, http.StatusForbidden)
+		case service.ErrInvalidRequest:
+			h.sendResponse(w, false, fmt.Sprintf("Укажите lat в [-90, 90], lng в [-180, 180], radius в (0, %.0f] м, duration_minutes >= 0 и action 'stop' или 'return_home'", service.MaxAreaRadius), nil, http.StatusBadRequest)
+		default:
+			h.sendServerError(w, r, "Ошибка закрытия воздушного пространства", err)
+		}
+		return
+	}
+
+	h.sendResponse(w, true, "Воздушное пространство закрыто", result, http.StatusOK)
+}
+
+func (h *DroneHandlers) PoliceStopArea(w http.ResponseWriter, r *http.Request) {
+	var req models.PoliceAreaStopRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.droneService.PoliceStopArea(r.Context(), req)
+	if err != nil {
+		h.sendPoliceError(w, r, err, fmt.Sprintf("Укажите lat в [-90, 90], lng в [-180, 180] и radius в (0, %.0f] м", service.MaxAreaRadius))
+		return
+	}
+
+	h.sendResponse(w, true, "Дроны в зоне остановлены", result, http.StatusOK)
+}
+
+func (h *DroneHandlers) PoliceStopUser(w http.ResponseWriter, r *http.Request) {
+	var req models.PoliceUserStopRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.droneService.PoliceStopUser(r.Context(), req)
+	if err != nil {
+		h.sendPoliceError(w, r, err, "Укажите target_user_id")
+		return
+	}
+
+	h.sendResponse(w, true, "Дроны пользователя остановлены", result, http.StatusOK)
+}
+
+func (h *DroneHandlers) ForceLand(w http.ResponseWriter, r *http.Request) {
+	var req models.ForceLandRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+	r = r.WithContext(logging.With(r.Context(), "drone_id", req.DroneID))
+
+	if err := h.droneService.ForceLand(r.Context(), req); err != nil {
+		h.sendPoliceError(w, r, err, "Укажите lat в [-90, 90] и lng в [-180, 180]")
+		return
+	}
+
+	h.sendResponse(w, true, "Дрон заблокирован и выполняет посадку", nil, http.StatusOK)
+}
+
+func (h *DroneHandlers) LockDrone(w http.ResponseWriter, r *http.Request) {
+	var req models.LockDroneRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+	r = r.WithContext(logging.With(r.Context(), "drone_id", req.DroneID))
+
+	if err := h.droneService.LockDrone(r.Context(), req); err != nil {
+		h.sendPoliceError(w, r, err, "")
+		return
+	}
+
+	h.sendResponse(w, true, "Дрон заблокирован", nil, http.StatusOK)
+}
+
+func (h *DroneHandlers) UnlockDrone(w http.ResponseWriter, r *http.Request) {
+	var req models.LockDroneRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+	r = r.WithContext(logging.With(r.Context(), "drone_id", req.DroneID))
+
+	if err := h.droneService.UnlockDrone(r.Context(), req); err != nil {
+		h.sendPoliceError(w, r, err, "")
+		return
+	}
+
+	h.sendResponse(w, true, "Блокировка дрона снята", nil, http.StatusOK)
+}
+
+func (h *DroneHandlers) sendPoliceError(w http.ResponseWriter, r *http.Request, err error, invalidMessage string) {
+	switch err {
+	case service.ErrAccessDenied:
+		h.sendResponse(w, false, "Доступ запрещен", nil, http.StatusForbidden)
+	case service.ErrInvalidRequest:
+		h.sendResponse(w, false, invalidMessage, nil, http.StatusBadRequest)
+	case service.ErrDroneNotFound:
+		h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
+	case service.ErrDroneNotActivated:
+		h.sendResponse(w, false, "Дрон не активирован", nil, http.StatusBadRequest)
+	case service.ErrDroneNotLocked:
+		h.sendResponse(w, false, "Дрон не заблокирован", nil, http.StatusConflict)
+	default:
+		h.sendServerError(w, r, "Ошибка выполнения команды", err)
+	}
+}
+
 func (h *DroneHandlers) sendResponse(w http.ResponseWriter, success bool, message string, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -154,6 +335,27 @@ func (h *DroneHandlers) sendResponse(w http.ResponseWriter, success bool, messag
 		Message: message,
 		Data:    data,
 	}
+	if !success {
+		response.RequestID = w.Header().Get(requestid.Header)
+	}
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// sendServerError пишет ошибку в лог с полями запроса и отвечает 500.
+func (h *DroneHandlers) sendServerError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.ErrorContext(r.Context(), message, "error", err)
+	h.sendResponse(w, false, message, nil, http.StatusInternalServerError)
+}
+
+// WriteError отвечает ошибкой в формате API сервиса. Нужна middleware вне
+// пакета, например проверке подписи шлюза.
+func WriteError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.APIResponse{
+		Success:   false,
+		Message:   message,
+		RequestID: w.Header().Get(requestid.Header),
+	})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+
+	"drones-api/internal/models"
+	"drones-api/internal/service"
+)
+
+func (h *DroneHandlers) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOrganizationRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.droneService.CreateOrganization(r.Context(), req)
+	if err != nil {
+		h.sendOrgError(w, r, err, "Название организации обязательно")
+		return
+	}
+
+	h.sendResponse(w, true, "Организация создана", org, http.StatusCreated)
+}
+
+func (h *DroneHandlers) GetUserOrganizations(w http.ResponseWriter, r *http.Request) {
+	var req models.OrganizationRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	orgs, err := h.droneService.GetUserOrganizations(r.Context(), req)
+	if err != nil {
+		h.sendOrgError(w, r, err, "")
+		return
+	}
+
+	h.sendResponse(w, true, "Организации пользователя получены", orgs, http.StatusOK)
+}
+
+func (h *DroneHandlers) GetOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	var req models.OrganizationRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.droneService.GetOrganizationMembers(r.Context(), req)
+	if err != nil {
+		h.sendOrgError(w, r, err, "")
+		return
+	}
+
+	h.sendResponse(w, true, "Участники организации получены", members, http.StatusOK)
+}
+
+func (h *DroneHandlers) AddOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	var req models.OrganizationMemberRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.droneService.AddMember(r.Context(), req); err != nil {
+		h.sendOrgError(w, r, err, "Неверный участник или роль (owner, pilot, viewer); в организации должен остаться владелец")
+		return
+	}
+
+	h.sendResponse(w, true, "Участник организации сохранен", nil, http.StatusOK)
+}
+
+func (h *DroneHandlers) RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	var req models.OrganizationMemberRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.droneService.RemoveMember(r.Context(), req); err != nil {
+		h.sendOrgError(w, r, err, "Участник не найден или является последним владельцем")
+		return
+	}
+
+	h.sendResponse(w, true, "Участник исключен из организации", nil, http.StatusOK)
+}
+
+func (h *DroneHandlers) TransferDrone(w http.ResponseWriter, r *http.Request) {
+	var req models.TransferDroneRequest
+	if err := decodeRequest(r, &req); err != nil {
+		h.sendResponse(w, false, "Неверный формат JSON", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.droneService.TransferDrone(r.Context(), req); err != nil {
+		h.sendOrgError(w, r, err, "")
+		return
+	}
+
+	h.sendResponse(w, true, "Дрон передан организации", nil, http.StatusOK)
+}
+
+func (h *DroneHandlers) sendOrgError(w http.ResponseWriter, r *http.Request, err error, invalidMessage string) {
+	switch err {
+	case service.ErrAccessDenied:
+		h.sendResponse(w, false, "Доступ запрещен", nil, http.StatusForbidden)
+	case service.ErrInvalidRequest:
+		h.sendResponse(w, false, invalidMessage, nil, http.StatusBadRequest)
+	case service.ErrOrgNotFound:
+		h.sendResponse(w, false, "Организация не найдена", nil, http.StatusNotFound)
+	case service.ErrDroneNotFound:
+		h.sendResponse(w, false, "Дрон не найден", nil, http.StatusNotFound)
+	default:
+		h.sendServerError(w, r, "Ошибка работы с организацией", err)
+	}
+}
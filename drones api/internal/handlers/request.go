@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"common/gatewayauth"
+	"drones-api/internal/models"
+)
+
+// decodeRequest читает JSON тело запроса и подставляет в него пользователя
+// из личности, которую проверил gatewayauth.Middleware.
+func decodeRequest(r *http.Request, req interface{ SetCaller(models.Caller) }) error {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return err
+	}
+	if identity, ok := gatewayauth.FromContext(r.Context()); ok {
+		req.SetCaller(models.Caller{UserID: identity.UserID, UserPermissions: identity.Permissions})
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package models
+
+// Caller - пользователь, от имени которого выполняется запрос. Встраивается
+// в структуры запросов и заполняется из личности, подписанной шлюзом: из
+// JSON тела эти поля не читаются, иначе клиент мог бы выдать себя за другого
+// пользователя или полицию.
+type Caller struct {
+	UserID          int      `json:"-"`
+	UserPermissions []string `json:"-"`
+}
+
+func (c *Caller) SetCaller(caller Caller) {
+	*c = caller
+}
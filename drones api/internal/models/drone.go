@@ -6,6 +6,7 @@ type Drone struct {
 	ID              int        `json:"id" db:"id"`
 	Name            string     `json:"name" db:"name"`
 	OwnerID         int        `json:"owner_id" db:"owner_id"`
+	OrganizationID  *int       `json:"organization_id" db:"organization_id"`
 	CurrentLat      *float64   `json:"current_lat" db:"current_lat"`
 	CurrentLng      *float64   `json:"current_lng" db:"current_lng"`
 	CurrentAltitude *float64   `json:"current_altitude" db:"current_altitude"`
@@ -17,13 +18,14 @@ type Drone struct {
 }
 
 type CreateDroneRequest struct {
-	UserID   int     `json:"user_id"`
-	Name     string  `json:"name"`
-	MaxSpeed float64 `json:"max_speed"`
+	Caller
+	Name           string  `json:"name"`
+	MaxSpeed       float64 `json:"max_speed"`
+	OrganizationID *int    `json:"organization_id,omitempty"`
 }
 
 type ActivateDroneRequest struct {
-	UserID   int     `json:"user_id"`
+	Caller
 	DroneID  int     `json:"drone_id"`
 	Lat      float64 `json:"lat"`
 	Lng      float64 `json:"lng"`
@@ -31,7 +33,7 @@ type ActivateDroneRequest struct {
 }
 
 type MoveDroneRequest struct {
-	UserID         int     `json:"user_id"`
+	Caller
 	DroneID        int     `json:"drone_id"`
 	TargetLat      float64 `json:"target_lat"`
 	TargetLng      float64 `json:"target_lng"`
@@ -41,22 +43,93 @@ type MoveDroneRequest struct {
 }
 
 type DroneInfoRequest struct {
-	UserID  int `json:"user_id"`
+	Caller
 	DroneID int `json:"drone_id"`
 }
 
 type StopDroneRequest struct {
-	UserID   int `json:"user_id"`
-	DroneID  int `json:"drone_id"`
-	UserRole int `json:"user_role"`
+	Caller
+	DroneID int `json:"drone_id"`
+}
+
+type DecommissionDroneRequest struct {
+	Caller
+	DroneID int `json:"drone_id"`
+}
+
+const (
+	LockdownActionStop       = "stop"
+	LockdownActionReturnHome = "return_home"
+)
+
+type LockdownRequest struct {
+	Caller
+	Name string `json:"name"`
+	// Lat и Lng обязательны: nil отличает пропущенный центр зоны от нулевого.
+	Lat             *float64 `json:"lat"`
+	Lng             *float64 `json:"lng"`
+	Radius          float64  `json:"radius"`
+	Altitude        float64  `json:"altitude"`
+	DurationMinutes int      `json:"duration_minutes"`
+	Action          string   `json:"action"`
+}
+
+type AffectedDrone struct {
+	DroneID    int     `json:"drone_id"`
+	OwnerID    int     `json:"owner_id"`
+	OwnerName  string  `json:"owner_name"`
+	OwnerEmail string  `json:"owner_email"`
+	Action     string  `json:"action"`
+	Lat        float64 `json:"lat"`
+	Lng        float64 `json:"lng"`
+	Altitude   float64 `json:"altitude"`
+}
+
+type LockdownResult struct {
+	AreaID          int             `json:"area_id"`
+	ExpiresAt       time.Time       `json:"expires_at"`
+	AffectedDrones  []AffectedDrone `json:"affected_drones"`
+	RevokedRequests []int           `json:"revoked_requests"`
+}
+
+type PoliceAreaStopRequest struct {
+	Caller
+	Lat    float64 `json:"lat"`
+	Lng    float64 `json:"lng"`
+	Radius float64 `json:"radius"`
+}
+
+type PoliceUserStopRequest struct {
+	Caller
+	TargetUserID int `json:"target_user_id"`
+}
+
+type ForceLandRequest struct {
+	Caller
+	DroneID int `json:"drone_id"`
+	// Lat и Lng - обязательная точка посадки.
+	Lat *float64 `json:"lat"`
+	Lng *float64 `json:"lng"`
+}
+
+type LockDroneRequest struct {
+	Caller
+	DroneID int    `json:"drone_id"`
+	Reason  string `json:"reason"`
+}
+
+type PoliceStopResult struct {
+	StoppedDrones []int `json:"stopped_drones"`
 }
 
 type GetUserDronesRequest struct {
-	UserID int `json:"user_id"`
+	Caller
 }
 
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	// RequestID помогает найти запрос в логах и трассировках, если он не удался.
+	RequestID string `json:"request_id,omitempty"`
 }
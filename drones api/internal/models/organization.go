@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+const (
+	OrgRoleOwner  = "owner"
+	OrgRolePilot  = "pilot"
+	OrgRoleViewer = "viewer"
+)
+
+type Organization struct {
+	ID        int        `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	CreatedBy int        `json:"created_by" db:"created_by"`
+	Role      string     `json:"role,omitempty" db:"role"`
+	CreatedAt *time.Time `json:"created_at" db:"created_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID int        `json:"organization_id" db:"organization_id"`
+	UserID         int        `json:"user_id" db:"user_id"`
+	Role           string     `json:"role" db:"role"`
+	CreatedAt      *time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Caller
+	Name string `json:"name"`
+}
+
+type OrganizationMemberRequest struct {
+	Caller
+	OrganizationID int    `json:"organization_id"`
+	MemberUserID   int    `json:"member_user_id"`
+	Role           string `json:"role"`
+}
+
+type OrganizationRequest struct {
+	Caller
+	OrganizationID int `json:"organization_id"`
+}
+
+type TransferDroneRequest struct {
+	Caller
+	DroneID        int `json:"drone_id"`
+	OrganizationID int `json:"organization_id"`
+}
@@ -1,116 +1,185 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"math"
 	"sync"
 	"time"
 
+	"common/logging"
 	"drones-api/internal/models"
 )
 
 type DroneService struct {
 	db           *sql.DB
-	movingDrones map[int]chan bool
+	movingDrones map[int]*movement
 	mu           sync.RWMutex
+
+	// flights ждет Shutdown; после shuttingDown новые полеты не начинаются.
+	flights      sync.WaitGroup
+	shuttingDown bool
+
+	// Запретные зоны для проверки на каждом тике полета, см. cachedBlockAreas.
+	areasMu       sync.Mutex
+	areas         []blockArea
+	areasLoadedAt time.Time
+}
+
+// movement - полет дрона, который симулирует этот экземпляр.
+type movement struct {
+	stop chan bool
+	// done закрывается, когда полет сохранил итоговое состояние и завершился.
+	done chan struct{}
 }
 
 func NewDroneService(db *sql.DB) *DroneService {
 	return &DroneService{
 		db:           db,
-		movingDrones: make(map[int]chan bool),
+		movingDrones: make(map[int]*movement),
 	}
 }
 
-func (ds *DroneService) CreateDrone(req models.CreateDroneRequest) (*models.Drone, error) {
+func (ds *DroneService) CreateDrone(ctx context.Context, req models.CreateDroneRequest) (*models.Drone, error) {
+	if req.OrganizationID != nil {
+		if err := ds.requireOrgOwner(ctx, *req.OrganizationID, req.UserID); err != nil {
+			return nil, err
+		}
+	}
+
 	query := `
-		INSERT INTO drones (name, owner_id, max_speed, current_status, battery_level, created_at, updated_at)
-		VALUES ($1, $2, $3, 'offline', 100, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO drones (name, owner_id, organization_id, max_speed, current_status, battery_level, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 'offline', 100, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING id`
 
 	var droneID int
-	err := ds.db.QueryRow(query, req.Name, req.UserID, req.MaxSpeed).Scan(&droneID)
+	err := ds.db.QueryRowContext(ctx, query, req.Name, req.UserID, req.OrganizationID, req.MaxSpeed).Scan(&droneID)
 	if err != nil {
-		fmt.Printf("Ошибка вставки дрона: %v\n", err)
+		slog.ErrorContext(ctx, "Ошибка вставки дрона", "error", err)
 		return nil, err
 	}
 
 	selectQuery := `
-		SELECT id, name, owner_id, current_lat, current_lng, current_altitude,
+		SELECT id, name, owner_id, organization_id, current_lat, current_lng, current_altitude,
 		       current_status, battery_level, max_speed, created_at, updated_at
 		FROM drones WHERE id = $1`
 
 	var drone models.Drone
-	err = ds.db.QueryRow(selectQuery, droneID).Scan(
-		&drone.ID, &drone.Name, &drone.OwnerID, &drone.CurrentLat,
+	err = ds.db.QueryRowContext(ctx, selectQuery, droneID).Scan(
+		&drone.ID, &drone.Name, &drone.OwnerID, &drone.OrganizationID, &drone.CurrentLat,
 		&drone.CurrentLng, &drone.CurrentAltitude, &drone.CurrentStatus,
 		&drone.BatteryLevel, &drone.MaxSpeed, &drone.CreatedAt, &drone.UpdatedAt)
 
 	if err != nil {
-		fmt.Printf("Ошибка получения созданного дрона: %v\n", err)
+		slog.ErrorContext(ctx, "Ошибка получения созданного дрона", "drone_id", droneID, "error", err)
 		return nil, err
 	}
 
 	return &drone, nil
 }
 
-func (ds *DroneService) ActivateDrone(req models.ActivateDroneRequest) error {
-	var ownerID int
-	err := ds.db.QueryRow("SELECT owner_id FROM drones WHERE id = $1", req.DroneID).Scan(&ownerID)
+func (ds *DroneService) ActivateDrone(ctx context.Context, req models.ActivateDroneRequest) error {
+	ownerID, role, err := ds.droneAccess(ctx, req.DroneID, req.UserID)
 	if err != nil {
 		return err
 	}
 
-	if ownerID != req.UserID {
+	if !roleAtLeast(role, models.OrgRolePilot) {
 		return ErrAccessDenied
 	}
 
+	if locked, err := ds.isLocked(ctx, req.DroneID); err != nil {
+		return err
+	} else if locked {
+		return ErrDroneLocked
+	}
+
+	if decommissioned, err := ds.isDecommissioned(ctx, req.DroneID); err != nil {
+		return err
+	} else if decommissioned {
+		return ErrDroneDecommissioned
+	}
+
 	query := `
 		UPDATE drones 
 		SET current_lat = $1, current_lng = $2, current_altitude = $3, 
 		    current_status = 'active', battery_level = 100, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $4`
 
-	_, err = ds.db.Exec(query, req.Lat, req.Lng, req.Altitude, req.DroneID)
-	return err
+	result, err := ds.db.ExecContext(ctx, query, req.Lat, req.Lng, req.Altitude, req.DroneID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrDroneNotFound
+	}
+
+	ds.emitStatusChanged(ctx, req.DroneID, ownerID, "active")
+	return nil
 }
 
-func (ds *DroneService) MoveDrone(req models.MoveDroneRequest) error {
+func (ds *DroneService) MoveDrone(ctx context.Context, req models.MoveDroneRequest) error {
+	_, role, err := ds.droneAccess(ctx, req.DroneID, req.UserID)
+	if err != nil {
+		return err
+	}
+
+	if !roleAtLeast(role, models.OrgRolePilot) {
+		return ErrAccessDenied
+	}
+
+	if locked, err := ds.isLocked(ctx, req.DroneID); err != nil {
+		return err
+	} else if locked {
+		return ErrDroneLocked
+	}
+
+	if decommissioned, err := ds.isDecommissioned(ctx, req.DroneID); err != nil {
+		return err
+	} else if decommissioned {
+		return ErrDroneDecommissioned
+	}
+
+	// Новый полет начинается с позиции, на которой остановился прежний.
+	if _, err := ds.endFlight(ctx, req.DroneID); err != nil {
+		return err
+	}
+
 	var drone models.Drone
 	query := `SELECT id, owner_id, current_lat, current_lng, current_altitude, current_status, battery_level 
 	          FROM drones WHERE id = $1`
 
-	err := ds.db.QueryRow(query, req.DroneID).Scan(
+	err = ds.db.QueryRowContext(ctx, query, req.DroneID).Scan(
 		&drone.ID, &drone.OwnerID, &drone.CurrentLat, &drone.CurrentLng,
 		&drone.CurrentAltitude, &drone.CurrentStatus, &drone.BatteryLevel)
 
+	if err == sql.ErrNoRows {
+		return ErrDroneNotFound
+	}
 	if err != nil {
 		return err
 	}
 
-	if drone.OwnerID != req.UserID {
-		return ErrAccessDenied
-	}
-
 	if drone.CurrentLat == nil || drone.CurrentLng == nil || drone.CurrentAltitude == nil {
 		return ErrDroneNotActivated
 	}
 
-	ds.stopDroneMovement(req.DroneID)
-
-	go ds.simulateMovement(req.DroneID, *drone.CurrentLat, *drone.CurrentLng, *drone.CurrentAltitude,
+	go ds.simulateMovement(ctx, req.DroneID, drone.OwnerID, *drone.CurrentLat, *drone.CurrentLng, *drone.CurrentAltitude,
 		req.TargetLat, req.TargetLng, req.TargetAltitude, req.BatteryLevel, req.Speed)
 
 	return nil
 }
 
-func (ds *DroneService) GetActiveDrones() ([]models.Drone, error) {
-	query := `SELECT id, name, owner_id, current_lat, current_lng, current_altitude, 
+func (ds *DroneService) GetActiveDrones(ctx context.Context) ([]models.Drone, error) {
+	query := `SELECT id, name, owner_id, organization_id, current_lat, current_lng, current_altitude, 
 	          current_status, battery_level, max_speed, created_at, updated_at 
 	          FROM drones WHERE current_status != 'offline'`
 
-	rows, err := ds.db.Query(query)
+	rows, err := ds.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +188,7 @@ func (ds *DroneService) GetActiveDrones() ([]models.Drone, error) {
 	var drones []models.Drone
 	for rows.Next() {
 		var drone models.Drone
-		err := rows.Scan(&drone.ID, &drone.Name, &drone.OwnerID, &drone.CurrentLat,
+		err := rows.Scan(&drone.ID, &drone.Name, &drone.OwnerID, &drone.OrganizationID, &drone.CurrentLat,
 			&drone.CurrentLng, &drone.CurrentAltitude, &drone.CurrentStatus,
 			&drone.BatteryLevel, &drone.MaxSpeed, &drone.CreatedAt, &drone.UpdatedAt)
 		if err != nil {
@@ -131,12 +200,15 @@ func (ds *DroneService) GetActiveDrones() ([]models.Drone, error) {
 	return drones, nil
 }
 
-func (ds *DroneService) GetUserDrones(req models.GetUserDronesRequest) ([]models.Drone, error) {
-	query := `SELECT id, name, owner_id, current_lat, current_lng, current_altitude, 
+func (ds *DroneService) GetUserDrones(ctx context.Context, req models.GetUserDronesRequest) ([]models.Drone, error) {
+	query := `SELECT id, name, owner_id, organization_id, current_lat, current_lng, current_altitude, 
 	          current_status, battery_level, max_speed, created_at, updated_at 
-	          FROM drones WHERE owner_id = $1 ORDER BY id`
+	          FROM drones
+	          WHERE owner_id = $1
+	             OR organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)
+	          ORDER BY id`
 
-	rows, err := ds.db.Query(query, req.UserID)
+	rows, err := ds.db.QueryContext(ctx, query, req.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -145,7 +217,7 @@ func (ds *DroneService) GetUserDrones(req models.GetUserDronesRequest) ([]models
 	var drones []models.Drone
 	for rows.Next() {
 		var drone models.Drone
-		err := rows.Scan(&drone.ID, &drone.Name, &drone.OwnerID, &drone.CurrentLat,
+		err := rows.Scan(&drone.ID, &drone.Name, &drone.OwnerID, &drone.OrganizationID, &drone.CurrentLat,
 			&drone.CurrentLng, &drone.CurrentAltitude, &drone.CurrentStatus,
 			&drone.BatteryLevel, &drone.MaxSpeed, &drone.CreatedAt, &drone.UpdatedAt)
 		if err != nil {
@@ -157,53 +229,141 @@ func (ds *DroneService) GetUserDrones(req models.GetUserDronesRequest) ([]models
 	return drones, nil
 }
 
-func (ds *DroneService) GetDroneInfo(req models.DroneInfoRequest) (*models.Drone, error) {
-	query := `SELECT id, name, owner_id, current_lat, current_lng, current_altitude, 
+func (ds *DroneService) GetDroneInfo(ctx context.Context, req models.DroneInfoRequest) (*models.Drone, error) {
+	_, role, err := ds.droneAccess(ctx, req.DroneID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !roleAtLeast(role, models.OrgRoleViewer) {
+		return nil, ErrAccessDenied
+	}
+
+	query := `SELECT id, name, owner_id, organization_id, current_lat, current_lng, current_altitude, 
 	          current_status, battery_level, max_speed, created_at, updated_at 
 	          FROM drones WHERE id = $1`
 
 	var drone models.Drone
-	err := ds.db.QueryRow(query, req.DroneID).Scan(
-		&drone.ID, &drone.Name, &drone.OwnerID, &drone.CurrentLat,
+	err = ds.db.QueryRowContext(ctx, query, req.DroneID).Scan(
+		&drone.ID, &drone.Name, &drone.OwnerID, &drone.OrganizationID, &drone.CurrentLat,
 		&drone.CurrentLng, &drone.CurrentAltitude, &drone.CurrentStatus,
 		&drone.BatteryLevel, &drone.MaxSpeed, &drone.CreatedAt, &drone.UpdatedAt)
 
+	if err == sql.ErrNoRows {
+		return nil, ErrDroneNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if drone.OwnerID != req.UserID {
-		return nil, ErrAccessDenied
+	return &drone, nil
+}
+
+func (ds *DroneService) StopDrone(ctx context.Context, req models.StopDroneRequest) error {
+	ownerID, role, err := ds.droneAccess(ctx, req.DroneID, req.UserID)
+	if err != nil {
+		return err
 	}
 
-	return &drone, nil
+	canForceStop := hasPermission(req.UserPermissions, PermDronesForceStop)
+	if !roleAtLeast(role, models.OrgRolePilot) && !canForceStop {
+		return ErrAccessDenied
+	}
+
+	// Владелец не может прервать принудительную посадку заблокированного дрона.
+	if !canForceStop {
+		if locked, err := ds.isLocked(ctx, req.DroneID); err != nil {
+			return err
+		} else if locked {
+			return ErrDroneLocked
+		}
+	}
+
+	if _, err := ds.endFlight(ctx, req.DroneID); err != nil {
+		return err
+	}
+
+	if !roleAtLeast(role, models.OrgRolePilot) {
+		ds.emitEvent(ctx, ownerID, EventPoliceStop, map[string]interface{}{
+			"drone_id":       req.DroneID,
+			"police_user_id": req.UserID,
+		})
+	}
+
+	return nil
 }
 
-func (ds *DroneService) StopDrone(req models.StopDroneRequest) error {
-	var ownerID int
-	err := ds.db.QueryRow("SELECT owner_id FROM drones WHERE id = $1", req.DroneID).Scan(&ownerID)
+// DecommissionDrone выводит дрон из эксплуатации. Это необратимо: такой дрон
+// нельзя активировать, отправить в полет или указать в заявке на полет.
+func (ds *DroneService) DecommissionDrone(ctx context.Context, req models.DecommissionDroneRequest) error {
+	ownerID, role, err := ds.droneAccess(ctx, req.DroneID, req.UserID)
 	if err != nil {
 		return err
 	}
 
-	if ownerID != req.UserID && req.UserRole != 2 {
+	if !roleAtLeast(role, models.OrgRoleOwner) {
 		return ErrAccessDenied
 	}
 
-	ds.stopDroneMovement(req.DroneID)
+	if _, err := ds.endFlight(ctx, req.DroneID); err != nil {
+		return err
+	}
+
+	result, err := ds.db.ExecContext(ctx, `
+		UPDATE drones SET decommissioned_at = CURRENT_TIMESTAMP, current_status = 'offline', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND decommissioned_at IS NULL`, req.DroneID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrDroneDecommissioned
+	}
+
+	ds.emitStatusChanged(ctx, req.DroneID, ownerID, "decommissioned")
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d вывел из эксплуатации дрон ID %d", req.UserID, req.DroneID))
 	return nil
 }
 
-func (ds *DroneService) simulateMovement(droneID int, startLat, startLng, startAlt, targetLat, targetLng, targetAlt float64, batteryLevel int, speed float64) {
+func (ds *DroneService) isDecommissioned(ctx context.Context, droneID int) (bool, error) {
+	var decommissioned bool
+	err := ds.db.QueryRowContext(ctx, `SELECT decommissioned_at IS NOT NULL FROM drones WHERE id = $1`, droneID).Scan(&decommissioned)
+	return decommissioned, err
+}
+
+func (ds *DroneService) simulateMovement(ctx context.Context, droneID, ownerID int, startLat, startLng, startAlt, targetLat, targetLng, targetAlt float64, batteryLevel int, speed float64) {
+	m := &movement{
+		stop: make(chan bool),
+		done: make(chan struct{}),
+	}
+
 	ds.mu.Lock()
-	stopChan := make(chan bool)
-	ds.movingDrones[droneID] = stopChan
+	if ds.shuttingDown {
+		ds.mu.Unlock()
+		return
+	}
+	ds.movingDrones[droneID] = m
+	ds.flights.Add(1)
 	ds.mu.Unlock()
+	defer ds.flights.Done()
+	defer close(m.done)
 
-	ds.db.Exec("UPDATE drones SET current_status = 'flying', updated_at = CURRENT_TIMESTAMP WHERE id = $1", droneID)
+	// Полет переживает запрос, который его начал, поэтому от контекста
+	// запроса берутся только поля логов.
+	ctx = logging.With(logging.Detach(ctx), "drone_id", droneID, "user_id", ownerID)
+
+	flightID, err := ds.startFlight(ctx, droneID, startLat, startLng, startAlt, targetLat, targetLng, targetAlt, speed)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка начала полета", "error", err)
+		ds.finishMovement(droneID, m)
+		return
+	}
+	ds.emitStatusChanged(ctx, droneID, ownerID, "flying")
 
 	currentLat, currentLng, currentAlt := startLat, startLng, startAlt
 	currentBattery := batteryLevel
+	breachedAreas := make(map[int]bool)
 
 	totalDistance := ds.calculateDistance(startLat, startLng, targetLat, targetLng)
 	altitudeDiff := targetAlt - startAlt
@@ -213,22 +373,29 @@ func (ds *DroneService) simulateMovement(droneID int, startLat, startLng, startA
 
 	for {
 		select {
-		case <-stopChan:
-			ds.db.Exec(`UPDATE drones SET current_status = 'stopped', battery_level = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
-				currentBattery, droneID)
-			ds.mu.Lock()
-			delete(ds.movingDrones, droneID)
-			ds.mu.Unlock()
+		case <-m.stop:
+			// Если полет уже завершил endFlight, статус записан и событие
+			// отправлено им.
+			if ds.saveFlightState(ctx, `UPDATE drones SET current_status = 'stopped', flight_id = NULL, current_lat = $1, current_lng = $2,
+			            current_altitude = $3, battery_level = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $5 AND flight_id = $6`,
+				currentLat, currentLng, currentAlt, currentBattery, droneID, flightID) {
+				ds.emitStatusChanged(ctx, droneID, ownerID, "stopped")
+			}
+			ds.finishMovement(droneID, m)
 			return
 
 		case <-ticker.C:
+			tickStart := time.Now()
+
 			currentBattery = int(float64(currentBattery) - 0.02)
 			if currentBattery <= 0 {
 				currentBattery = 0
-				ds.db.Exec(`UPDATE drones SET current_status = 'nullbattery', battery_level = 0, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, droneID)
-				ds.mu.Lock()
-				delete(ds.movingDrones, droneID)
-				ds.mu.Unlock()
+				if ds.saveFlightState(ctx, `UPDATE drones SET current_status = 'nullbattery', flight_id = NULL, battery_level = 0,
+				            updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND flight_id = $2`, droneID, flightID) {
+					ds.emitStatusChanged(ctx, droneID, ownerID, "nullbattery")
+				}
+				ds.finishMovement(droneID, m)
+				simulatorTickDuration.Observe(time.Since(tickStart).Seconds())
 				return
 			}
 
@@ -239,12 +406,14 @@ func (ds *DroneService) simulateMovement(droneID int, startLat, startLng, startA
 			if distanceToTarget <= distancePerSecond {
 				// Достигли цели
 				currentLat, currentLng, currentAlt = targetLat, targetLng, targetAlt
-				ds.db.Exec(`UPDATE drones SET current_lat = $1, current_lng = $2, current_altitude = $3, 
-				            current_status = 'active', battery_level = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $5`,
-					currentLat, currentLng, currentAlt, currentBattery, droneID)
-				ds.mu.Lock()
-				delete(ds.movingDrones, droneID)
-				ds.mu.Unlock()
+				if ds.saveFlightState(ctx, `UPDATE drones SET current_lat = $1, current_lng = $2, current_altitude = $3, 
+				            current_status = 'active', flight_id = NULL, battery_level = $4, updated_at = CURRENT_TIMESTAMP
+				            WHERE id = $5 AND flight_id = $6`,
+					currentLat, currentLng, currentAlt, currentBattery, droneID, flightID) {
+					ds.emitStatusChanged(ctx, droneID, ownerID, "active")
+				}
+				ds.finishMovement(droneID, m)
+				simulatorTickDuration.Observe(time.Since(tickStart).Seconds())
 				return
 			}
 
@@ -257,13 +426,34 @@ func (ds *DroneService) simulateMovement(droneID int, startLat, startLng, startA
 			currentLng += lngDiff
 			currentAlt += altDiff
 
-			ds.db.Exec(`UPDATE drones SET current_lat = $1, current_lng = $2, current_altitude = $3, 
-			            battery_level = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $5`,
-				currentLat, currentLng, currentAlt, currentBattery, droneID)
+			// Полет остановили или сменили, возможно на другом экземпляре.
+			if !ds.saveFlightState(ctx, `UPDATE drones SET current_lat = $1, current_lng = $2, current_altitude = $3, 
+			            battery_level = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $5 AND flight_id = $6`,
+				currentLat, currentLng, currentAlt, currentBattery, droneID, flightID) {
+				ds.finishMovement(droneID, m)
+				simulatorTickDuration.Observe(time.Since(tickStart).Seconds())
+				return
+			}
+
+			ds.checkGeofence(ctx, droneID, ownerID, currentLat, currentLng, currentAlt, breachedAreas)
+			simulatorTickDuration.Observe(time.Since(tickStart).Seconds())
 		}
 	}
 }
 
+// saveFlightState сохраняет состояние полета, если он еще текущий: запрос
+// сверяет flight_id. Возвращает false, если полет завершили или сменили.
+// Ошибка не прерывает полет: следующий тик снова запишет позицию.
+func (ds *DroneService) saveFlightState(ctx context.Context, query string, args ...interface{}) bool {
+	result, err := ds.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения состояния полета", "error", err)
+		return true
+	}
+	updated, err := result.RowsAffected()
+	return err != nil || updated > 0
+}
+
 func (ds *DroneService) calculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
 	const earthRadius = 6371000
 
@@ -280,12 +470,63 @@ func (ds *DroneService) calculateDistance(lat1, lng1, lat2, lng2 float64) float6
 	return earthRadius * c
 }
 
+// stopDroneMovement останавливает полет дрона на этом экземпляре и ждет его
+// завершения. Полеты на других экземплярах останавливает endFlight.
 func (ds *DroneService) stopDroneMovement(droneID int) {
+	ds.mu.Lock()
+	m, exists := ds.movingDrones[droneID]
+	if exists {
+		close(m.stop)
+		delete(ds.movingDrones, droneID)
+	}
+	ds.mu.Unlock()
+
+	if exists {
+		<-m.done
+	}
+}
+
+// Shutdown останавливает все полеты этого процесса и ждет, пока каждый
+// сохранит статус stopped с последней позицией: иначе после остановки
+// процесса дроны навсегда остались бы в статусе flying.
+func (ds *DroneService) Shutdown(ctx context.Context) error {
+	ds.mu.Lock()
+	ds.shuttingDown = true
+	for droneID, m := range ds.movingDrones {
+		close(m.stop)
+		delete(ds.movingDrones, droneID)
+	}
+	ds.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ds.flights.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MovingCount - число дронов, полет которых симулирует этот процесс.
+func (ds *DroneService) MovingCount() int {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return len(ds.movingDrones)
+}
+
+// finishMovement убирает полет из movingDrones, только если за это время
+// дрону не был назначен новый полет.
+func (ds *DroneService) finishMovement(droneID int, m *movement) {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
 
-	if stopChan, exists := ds.movingDrones[droneID]; exists {
-		close(stopChan)
+	if ds.movingDrones[droneID] == m {
 		delete(ds.movingDrones, droneID)
 	}
 }
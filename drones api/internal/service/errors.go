@@ -3,7 +3,12 @@ package service
 import "errors"
 
 var (
-	ErrAccessDenied      = errors.New("доступ запрещен")
-	ErrDroneNotFound     = errors.New("дрон не найден")
-	ErrDroneNotActivated = errors.New("дрон не активирован")
+	ErrAccessDenied        = errors.New("доступ запрещен")
+	ErrDroneNotFound       = errors.New("дрон не найден")
+	ErrDroneNotActivated   = errors.New("дрон не активирован")
+	ErrInvalidRequest      = errors.New("неверные параметры запроса")
+	ErrDroneLocked         = errors.New("дрон заблокирован полицией")
+	ErrDroneNotLocked      = errors.New("дрон не заблокирован")
+	ErrDroneDecommissioned = errors.New("дрон выведен из эксплуатации")
+	ErrOrgNotFound         = errors.New("организация не найдена")
 )
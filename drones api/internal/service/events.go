@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+const (
+	EventGeofenceBreach = "drone.geofence_breach"
+	EventPoliceStop     = "drone.police_stop"
+
+	EventDroneStatusChanged = "drone.status_changed"
+	EventRequestRevoked     = "request.revoked"
+
+	// EventZoneCreated совпадает с событием map api о новой запретной зоне.
+	EventZoneCreated = "zone.created"
+)
+
+// emitEvent ставит событие в общую очередь notification_events,
+// откуда его забирает и доставляет police api.
+func (ds *DroneService) emitEvent(ctx context.Context, userID int, eventType string, payload map[string]interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка сериализации события", "event_type", eventType, "error", err)
+		return
+	}
+
+	_, err = ds.db.ExecContext(ctx, `INSERT INTO notification_events (user_id, event_type, payload) VALUES ($1, $2, $3)`,
+		userID, eventType, data)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка постановки события в очередь", "event_type", eventType, "error", err)
+	}
+}
+
+func (ds *DroneService) emitStatusChanged(ctx context.Context, droneID, ownerID int, status string) {
+	ds.emitEvent(ctx, ownerID, EventDroneStatusChanged, map[string]interface{}{
+		"drone_id": droneID,
+		"status":   status,
+	})
+}
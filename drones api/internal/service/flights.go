@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Полеты симулируют разные экземпляры сервиса, а запросы к дрону шлюз может
+// отправить на любой из них. Поэтому текущий полет хранится в базе:
+// drones.flight_id указывает на запись в drone_flights, и симулятор пишет
+// состояние только пока flight_id совпадает с его полетом. Сброс или смена
+// flight_id останавливает полет на любом экземпляре не позже следующего тика.
+
+// flight - текущий полет дрона и последняя сохраненная позиция.
+type flight struct {
+	droneID int
+	ownerID int
+
+	lat, lng, alt                   float64
+	startLat, startLng, startAlt    float64
+	targetLat, targetLng, targetAlt float64
+	speed                           float64
+}
+
+// currentFlights возвращает полеты всех летящих дронов, на каком бы
+// экземпляре они ни симулировались.
+func (ds *DroneService) currentFlights(ctx context.Context) ([]flight, error) {
+	rows, err := ds.db.QueryContext(ctx, `
+		SELECT d.id, d.owner_id, d.current_lat, d.current_lng, d.current_altitude,
+		       f.start_lat, f.start_lng, f.start_altitude, f.target_lat, f.target_lng, f.target_altitude, f.speed
+		FROM drones d
+		JOIN drone_flights f ON f.id = d.flight_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flights []flight
+	for rows.Next() {
+		var f flight
+		if err := rows.Scan(&f.droneID, &f.ownerID, &f.lat, &f.lng, &f.alt,
+			&f.startLat, &f.startLng, &f.startAlt, &f.targetLat, &f.targetLng, &f.targetAlt, &f.speed); err != nil {
+			return nil, err
+		}
+		flights = append(flights, f)
+	}
+
+	return flights, rows.Err()
+}
+
+// startFlight записывает новый полет и делает его текущим. Прежний полет
+// дрона на следующем тике увидит чужой flight_id и завершится, ничего не
+// записав.
+func (ds *DroneService) startFlight(ctx context.Context, droneID int, startLat, startLng, startAlt, targetLat, targetLng, targetAlt, speed float64) (int64, error) {
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var flightID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO drone_flights (drone_id, start_lat, start_lng, start_altitude, target_lat, target_lng, target_altitude, speed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		droneID, startLat, startLng, startAlt, targetLat, targetLng, targetAlt, speed).Scan(&flightID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE drones SET flight_id = $1, current_status = 'flying', updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		flightID, droneID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Хранится только текущий полет дрона.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM drone_flights WHERE drone_id = $1 AND id <> $2`, droneID, flightID); err != nil {
+		return 0, err
+	}
+
+	return flightID, tx.Commit()
+}
+
+// endFlight останавливает текущий полет дрона на любом экземпляре. Дрон
+// остается в позиции, сохраненной последним тиком, и после возврата ее уже
+// никто не перезапишет. Возвращает false, если дрон не летел.
+func (ds *DroneService) endFlight(ctx context.Context, droneID int) (bool, error) {
+	var ownerID int
+	err := ds.db.QueryRowContext(ctx, `
+		UPDATE drones SET flight_id = NULL, current_status = 'stopped', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND flight_id IS NOT NULL
+		RETURNING owner_id`, droneID).Scan(&ownerID)
+
+	// Полет этого экземпляра завершается сразу, не дожидаясь тика.
+	ds.stopDroneMovement(droneID)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ds.emitStatusChanged(ctx, droneID, ownerID, "stopped")
+	return true, nil
+}
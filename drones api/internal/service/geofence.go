@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// blockAreasTTL - как долго симулятор использует загруженный список зон.
+// Новая зона map api начинает действовать на полеты не позже чем через этот
+// срок.
+const blockAreasTTL = 3 * time.Second
+
+type blockArea struct {
+	ID        int
+	Name      string
+	Radius    float64
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+}
+
+// activeBlockAreas возвращает действующие запретные зоны из таблицы map api.
+func (ds *DroneService) activeBlockAreas(ctx context.Context) ([]blockArea, error) {
+	query := `SELECT id, name, radius, latitude, longitude, altitude
+	          FROM block_areas
+	          WHERE state = 'active' AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)`
+
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var areas []blockArea
+	for rows.Next() {
+		var area blockArea
+		if err := rows.Scan(&area.ID, &area.Name, &area.Radius, &area.Latitude, &area.Longitude, &area.Altitude); err != nil {
+			return nil, err
+		}
+		areas = append(areas, area)
+	}
+
+	return areas, nil
+}
+
+// cachedBlockAreas возвращает действующие зоны, перечитывая их не чаще раза в
+// blockAreasTTL: проверка идет каждую секунду для каждого летящего дрона.
+// При ошибке загрузки используется прежний список.
+func (ds *DroneService) cachedBlockAreas(ctx context.Context) ([]blockArea, error) {
+	ds.areasMu.Lock()
+	defer ds.areasMu.Unlock()
+
+	if time.Since(ds.areasLoadedAt) < blockAreasTTL {
+		return ds.areas, nil
+	}
+
+	areas, err := ds.activeBlockAreas(ctx)
+	if err != nil {
+		return ds.areas, err
+	}
+	ds.areas = areas
+	ds.areasLoadedAt = time.Now()
+	return areas, nil
+}
+
+// resetBlockAreas сбрасывает кеш зон, чтобы зона, созданная этим
+// экземпляром, сразу учитывалась в полетах.
+func (ds *DroneService) resetBlockAreas() {
+	ds.areasMu.Lock()
+	ds.areasLoadedAt = time.Time{}
+	ds.areasMu.Unlock()
+}
+
+// isInsideArea проверяет, находится ли точка внутри зоны. Зона - цилиндр радиусом
+// Radius метров от земли до высоты Altitude (0 - без ограничения по высоте).
+func (ds *DroneService) isInsideArea(area blockArea, lat, lng, alt float64) bool {
+	if area.Altitude > 0 && alt > area.Altitude {
+		return false
+	}
+	return ds.calculateDistance(lat, lng, area.Latitude, area.Longitude) <= area.Radius
+}
+
+// checkGeofence отправляет владельцу уведомление о входе дрона в запретную
+// зону. Для каждой зоны уведомление отправляется один раз за полет.
+func (ds *DroneService) checkGeofence(ctx context.Context, droneID, ownerID int, lat, lng, alt float64, breached map[int]bool) {
+	areas, err := ds.cachedBlockAreas(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка получения запретных зон", "error", err)
+	}
+
+	for _, area := range areas {
+		if breached[area.ID] || !ds.isInsideArea(area, lat, lng, alt) {
+			continue
+		}
+		breached[area.ID] = true
+		ds.emitEvent(ctx, ownerID, EventGeofenceBreach, map[string]interface{}{
+			"drone_id":  droneID,
+			"area_id":   area.ID,
+			"area_name": area.Name,
+			"lat":       lat,
+			"lng":       lng,
+			"altitude":  alt,
+		})
+	}
+}
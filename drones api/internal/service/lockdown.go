@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"drones-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+const defaultLockdownDuration = 60 * time.Minute
+
+// maxFlightDuration - наибольшая длительность полета по заявке. В заявке
+// указано только время вылета, поэтому по нему и этому пределу определяется,
+// мог ли полет еще не закончиться.
+const maxFlightDuration = 2 * time.Hour
+
+// Lockdown экстренно закрывает воздушное пространство: создает временную
+// запретную зону, останавливает или возвращает на точку взлета все дроны,
+// которые находятся в зоне или летят через нее, и отзывает затронутые
+// одобренные заявки на полет.
+func (ds *DroneService) Lockdown(ctx context.Context, req models.LockdownRequest) (*models.LockdownResult, error) {
+	if !hasPermission(req.UserPermissions, PermAirspaceLockdown) {
+		return nil, ErrAccessDenied
+	}
+
+	if req.Action == "" {
+		req.Action = models.LockdownActionStop
+	}
+	if req.Lat == nil || req.Lng == nil || !validCoordinates(*req.Lat, *req.Lng) ||
+		!validRadius(req.Radius) || req.DurationMinutes < 0 ||
+		(req.Action != models.LockdownActionStop && req.Action != models.LockdownActionReturnHome) {
+		return nil, ErrInvalidRequest
+	}
+	if req.Name == "" {
+		req.Name = "Экстренное закрытие воздушного пространства"
+	}
+
+	duration := defaultLockdownDuration
+	if req.DurationMinutes > 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+	expiresAt := time.Now().Add(duration)
+
+	area := blockArea{
+		Name:      req.Name,
+		Radius:    req.Radius,
+		Latitude:  *req.Lat,
+		Longitude: *req.Lng,
+		Altitude:  req.Altitude,
+	}
+
+	if err := ds.createBlockArea(ctx, req.UserID, &area, expiresAt); err != nil {
+		return nil, fmt.Errorf("ошибка создания запретной зоны: %w", err)
+	}
+	ds.resetBlockAreas()
+
+	result := &models.LockdownResult{
+		AreaID:          area.ID,
+		ExpiresAt:       expiresAt,
+		AffectedDrones:  []models.AffectedDrone{},
+		RevokedRequests: []int{},
+	}
+
+	flights, err := ds.currentFlights(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка получения текущих полетов при закрытии зоны", "area_id", area.ID, "error", err)
+	}
+
+	affectedIDs := make(map[int]bool)
+	for _, f := range flights {
+		affected, err := ds.clearDroneFromArea(ctx, f, area, req.Action)
+		if err != nil {
+			slog.ErrorContext(ctx, "Ошибка обработки дрона при закрытии зоны", "drone_id", f.droneID, "area_id", area.ID, "error", err)
+			continue
+		}
+		if affected == nil {
+			continue
+		}
+
+		affectedIDs[f.droneID] = true
+		result.AffectedDrones = append(result.AffectedDrones, *affected)
+
+		ds.emitEvent(ctx, affected.OwnerID, EventPoliceStop, map[string]interface{}{
+			"drone_id":       f.droneID,
+			"police_user_id": req.UserID,
+			"area_id":        area.ID,
+			"action":         affected.Action,
+		})
+	}
+
+	revoked, err := ds.revokeRequestsForArea(ctx, area, expiresAt, affectedIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка отзыва заявок при закрытии зоны", "area_id", area.ID, "error", err)
+	} else {
+		result.RevokedRequests = revoked
+	}
+
+	logMessage := fmt.Sprintf("Пользователь ID %d закрыл воздушное пространство (зона ID %d, радиус %.0f м, до %s). Затронуто дронов: %d, отозвано заявок: %d",
+		req.UserID, area.ID, area.Radius, expiresAt.Format("2006-01-02 15:04:05"), len(result.AffectedDrones), len(result.RevokedRequests))
+	ds.logActivity(ctx, req.UserID, logMessage)
+
+	return result, nil
+}
+
+// createBlockArea создает зону в таблице map api вместе с событием
+// zone.created, которое map api ставит в очередь при создании зоны. Запись
+// и событие сохраняются в одной транзакции, чтобы подписчики не пропустили
+// зону.
+func (ds *DroneService) createBlockArea(ctx context.Context, userID int, area *blockArea, expiresAt time.Time) error {
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var createdAt, updatedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO block_areas (user_id, name, radius, latitude, longitude, altitude, state, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'active', $7)
+		RETURNING id, created_at, updated_at`,
+		userID, area.Name, area.Radius, area.Latitude, area.Longitude, area.Altitude, expiresAt).Scan(&area.ID, &createdAt, &updatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Тело события совпадает с зоной, которую отдает map api.
+	data, err := json.Marshal(map[string]interface{}{
+		"id":         area.ID,
+		"user_id":    userID,
+		"name":       area.Name,
+		"radius":     area.Radius,
+		"latitude":   area.Latitude,
+		"longitude":  area.Longitude,
+		"altitude":   area.Altitude,
+		"state":      "active",
+		"expires_at": expiresAt,
+		"created_at": createdAt,
+		"updated_at": updatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO notification_events (user_id, event_type, payload) VALUES ($1, $2, $3)`,
+		userID, EventZoneCreated, data)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func validCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// MaxAreaRadius - наибольший радиус зоны, которую можно закрыть или в которой
+// можно остановить все дроны разом, м.
+const MaxAreaRadius = 50000.0
+
+func validRadius(radius float64) bool {
+	return radius > 0 && radius <= MaxAreaRadius
+}
+
+// clearDroneFromArea останавливает или разворачивает дрон, если он находится
+// в зоне или его маршрут проходит через нее. Возвращает nil, если дрон зону
+// не затрагивает.
+func (ds *DroneService) clearDroneFromArea(ctx context.Context, f flight, area blockArea, action string) (*models.AffectedDrone, error) {
+	if !ds.isInsideArea(area, f.lat, f.lng, f.alt) && !ds.pathCrossesArea(area, f.lat, f.lng, f.alt, f.targetLat, f.targetLng, f.targetAlt) {
+		return nil, nil
+	}
+
+	// Возврат домой невозможен, если путь к точке взлета сам проходит через зону.
+	if action == models.LockdownActionReturnHome &&
+		ds.pathCrossesArea(area, f.lat, f.lng, f.alt, f.startLat, f.startLng, f.startAlt) {
+		action = models.LockdownActionStop
+	}
+
+	// После endFlight позицию уже никто не перезапишет: от нее начинается возврат.
+	stopped, err := ds.endFlight(ctx, f.droneID)
+	if err != nil {
+		return nil, err
+	}
+	if !stopped {
+		// Полет успел завершиться сам.
+		return nil, nil
+	}
+
+	var lat, lng, alt float64
+	var battery int
+	err = ds.db.QueryRowContext(ctx, `SELECT current_lat, current_lng, current_altitude, battery_level FROM drones WHERE id = $1`, f.droneID).
+		Scan(&lat, &lng, &alt, &battery)
+	if err != nil {
+		return nil, err
+	}
+	if action == models.LockdownActionReturnHome {
+		go ds.simulateMovement(ctx, f.droneID, f.ownerID, lat, lng, alt, f.startLat, f.startLng, f.startAlt, battery, f.speed)
+	}
+
+	affected := &models.AffectedDrone{
+		DroneID:  f.droneID,
+		OwnerID:  f.ownerID,
+		Action:   action,
+		Lat:      lat,
+		Lng:      lng,
+		Altitude: alt,
+	}
+
+	err = ds.db.QueryRowContext(ctx, `SELECT full_name, email FROM users WHERE id = $1`, f.ownerID).
+		Scan(&affected.OwnerName, &affected.OwnerEmail)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка получения владельца дрона", "drone_id", f.droneID, "error", err)
+	}
+
+	return affected, nil
+}
+
+// revokeRequestsForArea отзывает одобренные заявки затронутых дронов и заявки,
+// маршрут которых проходит через зону до окончания ее действия. Рассматриваются
+// только текущие и предстоящие полеты: заявки, вылет по которым был раньше
+// maxFlightDuration, уже отлетаны и к зоне отношения не имеют.
+func (ds *DroneService) revokeRequestsForArea(ctx context.Context, area blockArea, until time.Time, affectedDrones map[int]bool) ([]int, error) {
+	rows, err := ds.db.QueryContext(ctx, `
+		SELECT id, user_id, drone_id, altitude, start_lat, start_lng, end_lat, end_lng
+		FROM flightrequest
+		WHERE state = 'approved' AND departure_time >= $1 AND departure_time <= $2`,
+		time.Now().Add(-maxFlightDuration), until)
+	if err != nil {
+		return nil, err
+	}
+
+	type approvedRequest struct {
+		id, userID, droneID                          int
+		altitude, startLat, startLng, endLat, endLng float64
+	}
+
+	var toRevoke []approvedRequest
+	for rows.Next() {
+		var r approvedRequest
+		if err := rows.Scan(&r.id, &r.userID, &r.droneID, &r.altitude, &r.startLat, &r.startLng, &r.endLat, &r.endLng); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if affectedDrones[r.droneID] || ds.pathCrossesArea(area, r.startLat, r.startLng, r.altitude, r.endLat, r.endLng, r.altitude) {
+			toRevoke = append(toRevoke, r)
+		}
+	}
+	rows.Close()
+
+	ids := []int{}
+	for _, r := range toRevoke {
+		ids = append(ids, r.id)
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	_, err = ds.db.ExecContext(ctx, `UPDATE flightrequest SET state = 'revoked' WHERE id = ANY($1) AND state = 'approved'`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range toRevoke {
+		ds.emitEvent(ctx, r.userID, EventRequestRevoked, map[string]interface{}{
+			"request_id": r.id,
+			"drone_id":   r.droneID,
+			"state":      "revoked",
+			"area_id":    area.ID,
+		})
+	}
+
+	return ids, nil
+}
+
+// pathCrossesArea проверяет, проходит ли прямой отрезок маршрута через зону.
+// На масштабах зон достаточно локальной равнопромежуточной проекции.
+func (ds *DroneService) pathCrossesArea(area blockArea, lat1, lng1, alt1, lat2, lng2, alt2 float64) bool {
+	if area.Altitude > 0 && alt1 > area.Altitude && alt2 > area.Altitude {
+		return false
+	}
+
+	const earthRadius = 6371000
+	toXY := func(lat, lng float64) (float64, float64) {
+		x := (lng - area.Longitude) * math.Pi / 180 * earthRadius * math.Cos(area.Latitude*math.Pi/180)
+		y := (lat - area.Latitude) * math.Pi / 180 * earthRadius
+		return x, y
+	}
+
+	ax, ay := toXY(lat1, lng1)
+	bx, by := toXY(lat2, lng2)
+	dx, dy := bx-ax, by-ay
+
+	t := 0.0
+	if lenSq := dx*dx + dy*dy; lenSq > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lenSq))
+	}
+
+	return math.Hypot(ax+t*dx, ay+t*dy) <= area.Radius
+}
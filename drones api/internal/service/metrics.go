@@ -0,0 +1,13 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// simulatorTickDuration - время одного шага симуляции полета.
+var simulatorTickDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "drones_simulator_tick_duration_seconds",
+	Help:    "Время одного шага симуляции полета дрона.",
+	Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+})
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"drones-api/internal/models"
+)
+
+var orgRoleLevels = map[string]int{
+	models.OrgRoleViewer: 1,
+	models.OrgRolePilot:  2,
+	models.OrgRoleOwner:  3,
+}
+
+// roleAtLeast сравнивает роль пользователя с минимально необходимой.
+// Пустая роль означает отсутствие доступа к дрону.
+func roleAtLeast(role, required string) bool {
+	return orgRoleLevels[role] >= orgRoleLevels[required] && orgRoleLevels[role] > 0
+}
+
+func validOrgRole(role string) bool {
+	_, ok := orgRoleLevels[role]
+	return ok
+}
+
+// droneAccess возвращает владельца дрона и роль пользователя по отношению к нему:
+// личный владелец считается owner, для дронов организации берется роль участника.
+// Если дрона нет, возвращает ErrDroneNotFound.
+func (ds *DroneService) droneAccess(ctx context.Context, droneID, userID int) (int, string, error) {
+	query := `
+		SELECT d.owner_id, d.organization_id, m.role
+		FROM drones d
+		LEFT JOIN organization_members m ON m.organization_id = d.organization_id AND m.user_id = $2
+		WHERE d.id = $1`
+
+	var ownerID int
+	var orgID sql.NullInt64
+	var memberRole sql.NullString
+	err := ds.db.QueryRowContext(ctx, query, droneID, userID).Scan(&ownerID, &orgID, &memberRole)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrDroneNotFound
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if orgID.Valid {
+		return ownerID, memberRole.String, nil
+	}
+	if ownerID == userID {
+		return ownerID, models.OrgRoleOwner, nil
+	}
+	return ownerID, "", nil
+}
+
+func (ds *DroneService) orgRole(ctx context.Context, orgID, userID int) (string, error) {
+	var exists bool
+	if err := ds.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM organizations WHERE id = $1)`, orgID).Scan(&exists); err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrOrgNotFound
+	}
+
+	var role string
+	err := ds.db.QueryRowContext(ctx, `SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2`,
+		orgID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+func (ds *DroneService) requireOrgOwner(ctx context.Context, orgID, userID int) error {
+	role, err := ds.orgRole(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if role != models.OrgRoleOwner {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (ds *DroneService) CreateOrganization(ctx context.Context, req models.CreateOrganizationRequest) (*models.Organization, error) {
+	if req.Name == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	org := models.Organization{Name: req.Name, CreatedBy: req.UserID, Role: models.OrgRoleOwner}
+	err = tx.QueryRowContext(ctx, `INSERT INTO organizations (name, created_by) VALUES ($1, $2) RETURNING id, created_at`,
+		req.Name, req.UserID).Scan(&org.ID, &org.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)`,
+		org.ID, req.UserID, models.OrgRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d создал организацию ID %d", req.UserID, org.ID))
+
+	return &org, nil
+}
+
+func (ds *DroneService) GetUserOrganizations(ctx context.Context, req models.OrganizationRequest) ([]models.Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.created_by, m.role, o.created_at
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.id`
+
+	rows, err := ds.db.QueryContext(ctx, query, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgs []models.Organization
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedBy, &org.Role, &org.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
+
+func (ds *DroneService) GetOrganizationMembers(ctx context.Context, req models.OrganizationRequest) ([]models.OrganizationMember, error) {
+	role, err := ds.orgRole(ctx, req.OrganizationID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !roleAtLeast(role, models.OrgRoleViewer) {
+		return nil, ErrAccessDenied
+	}
+
+	rows, err := ds.db.QueryContext(ctx, `
+		SELECT organization_id, user_id, role, created_at
+		FROM organization_members WHERE organization_id = $1 ORDER BY user_id`, req.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []models.OrganizationMember
+	for rows.Next() {
+		var member models.OrganizationMember
+		if err := rows.Scan(&member.OrganizationID, &member.UserID, &member.Role, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMember добавляет участника в организацию или меняет его роль.
+func (ds *DroneService) AddMember(ctx context.Context, req models.OrganizationMemberRequest) error {
+	if req.MemberUserID <= 0 || !validOrgRole(req.Role) {
+		return ErrInvalidRequest
+	}
+
+	if err := ds.requireOrgOwner(ctx, req.OrganizationID, req.UserID); err != nil {
+		return err
+	}
+
+	if req.MemberUserID == req.UserID && req.Role != models.OrgRoleOwner {
+		if err := ds.ensureAnotherOwner(ctx, req.OrganizationID, req.UserID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role`
+
+	if _, err := ds.db.ExecContext(ctx, query, req.OrganizationID, req.MemberUserID, req.Role); err != nil {
+		return err
+	}
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d назначил пользователю ID %d роль %s в организации ID %d",
+		req.UserID, req.MemberUserID, req.Role, req.OrganizationID))
+
+	return nil
+}
+
+func (ds *DroneService) RemoveMember(ctx context.Context, req models.OrganizationMemberRequest) error {
+	if req.MemberUserID <= 0 {
+		return ErrInvalidRequest
+	}
+
+	if err := ds.requireOrgOwner(ctx, req.OrganizationID, req.UserID); err != nil {
+		return err
+	}
+
+	if req.MemberUserID == req.UserID {
+		if err := ds.ensureAnotherOwner(ctx, req.OrganizationID, req.UserID); err != nil {
+			return err
+		}
+	}
+
+	result, err := ds.db.ExecContext(ctx, `DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`,
+		req.OrganizationID, req.MemberUserID)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return ErrInvalidRequest
+	}
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d исключил пользователя ID %d из организации ID %d",
+		req.UserID, req.MemberUserID, req.OrganizationID))
+
+	return nil
+}
+
+// ensureAnotherOwner не дает последнему владельцу покинуть организацию
+// или понизить себя, иначе флотом некому будет управлять.
+func (ds *DroneService) ensureAnotherOwner(ctx context.Context, orgID, userID int) error {
+	var owners int
+	err := ds.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM organization_members
+		WHERE organization_id = $1 AND role = $2 AND user_id != $3`,
+		orgID, models.OrgRoleOwner, userID).Scan(&owners)
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+// TransferDrone передает личный дрон в организацию. Передавать может только
+// владелец дрона, являющийся владельцем организации.
+func (ds *DroneService) TransferDrone(ctx context.Context, req models.TransferDroneRequest) error {
+	if err := ds.requireOrgOwner(ctx, req.OrganizationID, req.UserID); err != nil {
+		return err
+	}
+
+	_, role, err := ds.droneAccess(ctx, req.DroneID, req.UserID)
+	if err != nil {
+		return err
+	}
+	if role != models.OrgRoleOwner {
+		return ErrAccessDenied
+	}
+
+	if _, err := ds.db.ExecContext(ctx, `UPDATE drones SET organization_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		req.OrganizationID, req.DroneID); err != nil {
+		return err
+	}
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d передал дрон ID %d организации ID %d",
+		req.UserID, req.DroneID, req.OrganizationID))
+
+	return nil
+}
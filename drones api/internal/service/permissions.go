@@ -0,0 +1,16 @@
+package service
+
+// Права из личности пользователя, подписанной шлюзом (см. models.Caller).
+const (
+	PermDronesForceStop  = "drones:force_stop"
+	PermAirspaceLockdown = "airspace:lockdown"
+)
+
+func hasPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"drones-api/internal/models"
+)
+
+// Скорость принудительной посадки, если у дрона не указана max_speed, км/ч.
+const defaultForceLandSpeed = 36.0
+
+// PoliceStopArea останавливает все летящие дроны, находящиеся в круге.
+func (ds *DroneService) PoliceStopArea(ctx context.Context, req models.PoliceAreaStopRequest) (*models.PoliceStopResult, error) {
+	if !hasPermission(req.UserPermissions, PermDronesForceStop) {
+		return nil, ErrAccessDenied
+	}
+	if !validCoordinates(req.Lat, req.Lng) || !validRadius(req.Radius) {
+		return nil, ErrInvalidRequest
+	}
+
+	flights, err := ds.currentFlights(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	area := blockArea{Radius: req.Radius, Latitude: req.Lat, Longitude: req.Lng}
+	result := &models.PoliceStopResult{StoppedDrones: []int{}}
+
+	for _, f := range flights {
+		if !ds.isInsideArea(area, f.lat, f.lng, f.alt) {
+			continue
+		}
+
+		if ds.policeStop(ctx, f.droneID, f.ownerID, req.UserID) {
+			result.StoppedDrones = append(result.StoppedDrones, f.droneID)
+		}
+	}
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d остановил все дроны в радиусе %.0f м от (%.6f, %.6f). Остановлены: %v",
+		req.UserID, req.Radius, req.Lat, req.Lng, result.StoppedDrones))
+
+	return result, nil
+}
+
+// PoliceStopUser останавливает все летящие дроны пользователя.
+func (ds *DroneService) PoliceStopUser(ctx context.Context, req models.PoliceUserStopRequest) (*models.PoliceStopResult, error) {
+	if !hasPermission(req.UserPermissions, PermDronesForceStop) {
+		return nil, ErrAccessDenied
+	}
+	if req.TargetUserID <= 0 {
+		return nil, ErrInvalidRequest
+	}
+
+	flights, err := ds.currentFlights(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PoliceStopResult{StoppedDrones: []int{}}
+
+	for _, f := range flights {
+		if f.ownerID != req.TargetUserID {
+			continue
+		}
+
+		if ds.policeStop(ctx, f.droneID, f.ownerID, req.UserID) {
+			result.StoppedDrones = append(result.StoppedDrones, f.droneID)
+		}
+	}
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d остановил все дроны пользователя ID %d. Остановлены: %v",
+		req.UserID, req.TargetUserID, result.StoppedDrones))
+
+	return result, nil
+}
+
+// ForceLand блокирует дрон и сажает его в указанной точке.
+func (ds *DroneService) ForceLand(ctx context.Context, req models.ForceLandRequest) error {
+	if !hasPermission(req.UserPermissions, PermDronesForceStop) {
+		return ErrAccessDenied
+	}
+	if req.Lat == nil || req.Lng == nil || !validCoordinates(*req.Lat, *req.Lng) {
+		return ErrInvalidRequest
+	}
+	lat, lng := *req.Lat, *req.Lng
+
+	// Посадка начинается с позиции, которую сохранил остановленный полет.
+	if _, err := ds.endFlight(ctx, req.DroneID); err != nil {
+		return err
+	}
+
+	var drone models.Drone
+	err := ds.db.QueryRowContext(ctx, `SELECT id, owner_id, current_lat, current_lng, current_altitude, battery_level, max_speed
+	                       FROM drones WHERE id = $1`, req.DroneID).
+		Scan(&drone.ID, &drone.OwnerID, &drone.CurrentLat, &drone.CurrentLng, &drone.CurrentAltitude,
+			&drone.BatteryLevel, &drone.MaxSpeed)
+	if err == sql.ErrNoRows {
+		return ErrDroneNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if drone.CurrentLat == nil || drone.CurrentLng == nil || drone.CurrentAltitude == nil {
+		return ErrDroneNotActivated
+	}
+
+	if err := ds.lockDrone(ctx, req.DroneID, req.UserID, "Принудительная посадка"); err != nil {
+		return err
+	}
+
+	speed := drone.MaxSpeed
+	if speed <= 0 {
+		speed = defaultForceLandSpeed
+	}
+
+	go ds.simulateMovement(ctx, req.DroneID, drone.OwnerID, *drone.CurrentLat, *drone.CurrentLng, *drone.CurrentAltitude,
+		lat, lng, 0, drone.BatteryLevel, speed)
+
+	ds.emitEvent(ctx, drone.OwnerID, EventPoliceStop, map[string]interface{}{
+		"drone_id":       req.DroneID,
+		"police_user_id": req.UserID,
+		"action":         "force_land",
+		"lat":            lat,
+		"lng":            lng,
+	})
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d принудительно сажает дрон ID %d в точке (%.6f, %.6f)",
+		req.UserID, req.DroneID, lat, lng))
+
+	return nil
+}
+
+// LockDrone запрещает владельцу управлять дроном до снятия блокировки.
+func (ds *DroneService) LockDrone(ctx context.Context, req models.LockDroneRequest) error {
+	if !hasPermission(req.UserPermissions, PermDronesForceStop) {
+		return ErrAccessDenied
+	}
+
+	if err := ds.ensureDroneExists(ctx, req.DroneID); err != nil {
+		return err
+	}
+
+	if err := ds.lockDrone(ctx, req.DroneID, req.UserID, req.Reason); err != nil {
+		return err
+	}
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d заблокировал дрон ID %d. Причина: %s",
+		req.UserID, req.DroneID, req.Reason))
+
+	return nil
+}
+
+func (ds *DroneService) UnlockDrone(ctx context.Context, req models.LockDroneRequest) error {
+	if !hasPermission(req.UserPermissions, PermDronesForceStop) {
+		return ErrAccessDenied
+	}
+
+	result, err := ds.db.ExecContext(ctx, `DELETE FROM drone_locks WHERE drone_id = $1`, req.DroneID)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		if err := ds.ensureDroneExists(ctx, req.DroneID); err != nil {
+			return err
+		}
+		return ErrDroneNotLocked
+	}
+
+	ds.logActivity(ctx, req.UserID, fmt.Sprintf("Пользователь ID %d снял блокировку с дрона ID %d", req.UserID, req.DroneID))
+
+	return nil
+}
+
+// policeStop останавливает полет и уведомляет владельца. Возвращает false,
+// если дрон уже не летел.
+func (ds *DroneService) policeStop(ctx context.Context, droneID, ownerID, policeUserID int) bool {
+	stopped, err := ds.endFlight(ctx, droneID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка остановки полета", "drone_id", droneID, "error", err)
+		return false
+	}
+	if !stopped {
+		return false
+	}
+
+	ds.emitEvent(ctx, ownerID, EventPoliceStop, map[string]interface{}{
+		"drone_id":       droneID,
+		"police_user_id": policeUserID,
+	})
+	return true
+}
+
+func (ds *DroneService) lockDrone(ctx context.Context, droneID, policeUserID int, reason string) error {
+	query := `
+		INSERT INTO drone_locks (drone_id, locked_by, reason)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (drone_id) DO UPDATE SET locked_by = EXCLUDED.locked_by, reason = EXCLUDED.reason,
+		                                     created_at = CURRENT_TIMESTAMP`
+
+	_, err := ds.db.ExecContext(ctx, query, droneID, policeUserID, reason)
+	return err
+}
+
+func (ds *DroneService) isLocked(ctx context.Context, droneID int) (bool, error) {
+	var locked bool
+	err := ds.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM drone_locks WHERE drone_id = $1)`, droneID).Scan(&locked)
+	return locked, err
+}
+
+func (ds *DroneService) ensureDroneExists(ctx context.Context, droneID int) error {
+	var exists bool
+	err := ds.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM drones WHERE id = $1)`, droneID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrDroneNotFound
+	}
+	return nil
+}
+
+func (ds *DroneService) logActivity(ctx context.Context, userID int, message string) {
+	if _, err := ds.db.ExecContext(ctx, `INSERT INTO activitylogs (user_id, logs) VALUES ($1, $2)`, userID, message); err != nil {
+		slog.ErrorContext(ctx, "Ошибка создания лога активности", "error", err)
+	}
+}
@@ -1,14 +1,39 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"common/logging"
 	"drones-api/internal/app"
+	"drones-api/internal/config"
 )
 
 func main() {
-	application := app.NewApp()
-	if err := application.Run(); err != nil {
-		log.Fatal("Ошибка запуска приложения:", err)
+	configFile := flag.String("config", "", "файл с переменными окружения (по умолчанию .env, если есть)")
+	printConfig := flag.Bool("print-config", false, "вывести конфигурацию без секретов и выйти")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка конфигурации:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	// SIGTERM посылают оркестраторы, SIGINT - Ctrl+C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	application := app.NewApp(cfg)
+	if err := application.Run(ctx); err != nil {
+		logging.Fatal("Ошибка запуска приложения", "error", err)
 	}
 }
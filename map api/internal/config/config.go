@@ -0,0 +1,52 @@
+package config
+
+import (
+	"common/envconfig"
+	"io"
+	"time"
+)
+
+type Config struct {
+	Port        string
+	DatabaseURL string
+	// GatewayJWKSURL - открытые ключи шлюза для проверки подписи запросов.
+	GatewayJWKSURL string
+	// TracesExporter - куда отправлять спаны: otlp, stdout или none.
+	TracesExporter string
+	// LogLevel - минимальный уровень логов: debug, info, warn или error.
+	LogLevel string
+	// ShutdownTimeout ограничивает ожидание начатых запросов при остановке.
+	ShutdownTimeout time.Duration
+
+	// env - прочитанные переменные для --print-config.
+	env *envconfig.Loader
+}
+
+// Load читает конфигурацию из окружения и необязательного файла path
+// (по умолчанию .env) и проверяет ее. Ошибки возвращаются все сразу.
+func Load(path string) (*Config, error) {
+	if err := envconfig.LoadFile(path); err != nil {
+		return nil, err
+	}
+
+	l := &envconfig.Loader{}
+	cfg := &Config{
+		Port:            l.GetPortEnv("PORT", "8082"),
+		DatabaseURL:     l.RequireSecret("DATABASE_URL"),
+		GatewayJWKSURL:  l.GetURLEnv("GATEWAY_JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
+		TracesExporter:  l.GetEnumEnv("OTEL_TRACES_EXPORTER", "none", "otlp", "stdout", "none"),
+		LogLevel:        l.GetEnumEnv("LOG_LEVEL", "info", "debug", "info", "warn", "error"),
+		ShutdownTimeout: l.GetDurationEnv("SHUTDOWN_TIMEOUT", 15*time.Second),
+	}
+
+	cfg.env = l
+	if err := l.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Print выводит итоговую конфигурацию в формате .env без секретов.
+func (c *Config) Print(w io.Writer) {
+	c.env.Print(w)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"map-api/internal/models"
 	"map-api/internal/repository"
 	"net/http"
@@ -18,7 +19,7 @@ func NewBlockAreaHandler(repo *repository.BlockAreaRepository) *BlockAreaHandler
 
 func (h *BlockAreaHandler) CreateBlockArea(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateBlockAreaRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		http.Error(w, fmt.Sprintf("Ошибка декодирования JSON: %v", err), http.StatusBadRequest)
 		return
 	}
@@ -35,20 +36,24 @@ func (h *BlockAreaHandler) CreateBlockArea(w http.ResponseWriter, r *http.Reques
 		req.State = "active"
 	}
 
-	area, err := h.repo.Create(&req)
+	area, err := h.repo.Create(r.Context(), &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	if err := h.repo.CreateEvent(r.Context(), area.UserID, models.EventZoneCreated, area); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка создания события", "error", err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(area)
 }
 
 func (h *BlockAreaHandler) GetAllBlockAreas(w http.ResponseWriter, r *http.Request) {
-	areas, err := h.repo.GetAll()
+	areas, err := h.repo.GetAll(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -58,7 +63,7 @@ func (h *BlockAreaHandler) GetAllBlockAreas(w http.ResponseWriter, r *http.Reque
 
 func (h *BlockAreaHandler) UpdateBlockArea(w http.ResponseWriter, r *http.Request) {
 	var req models.UpdateBlockAreaRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		http.Error(w, fmt.Sprintf("Ошибка декодирования JSON: %v", err), http.StatusBadRequest)
 		return
 	}
@@ -72,9 +77,9 @@ func (h *BlockAreaHandler) UpdateBlockArea(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	area, err := h.repo.Update(&req)
+	area, err := h.repo.Update(r.Context(), &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -103,10 +108,16 @@ func (h *BlockAreaHandler) UpdateBlockArea(w http.ResponseWriter, r *http.Reques
 
 	logMessage := fmt.Sprintf("Пользователь ID %d изменил запись ID %d. Изменения: %v", req.UserID, req.ID, changes)
 
-	if err := h.repo.CreateActivityLog(req.UserID, logMessage); err != nil {
-		fmt.Printf("Ошибка создания лога активности: %v\n", err)
+	if err := h.repo.CreateActivityLog(r.Context(), req.UserID, logMessage); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка создания лога активности", "error", err)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(area)
 }
+
+// serverError пишет ошибку в лог с полями запроса и отвечает 500.
+func serverError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "Ошибка обработки запроса", "error", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
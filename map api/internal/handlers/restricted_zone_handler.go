@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"map-api/internal/models"
 	"map-api/internal/repository"
 	"net/http"
@@ -18,7 +19,7 @@ func NewRestrictedZoneHandler(repo *repository.RestrictedZoneRepository) *Restri
 
 func (h *RestrictedZoneHandler) CreateRestrictedZone(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateRestrictedZoneRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		http.Error(w, fmt.Sprintf("Ошибка декодирования JSON: %v", err), http.StatusBadRequest)
 		return
 	}
@@ -32,9 +33,9 @@ func (h *RestrictedZoneHandler) CreateRestrictedZone(w http.ResponseWriter, r *h
 		req.State = "active"
 	}
 
-	zone, err := h.repo.Create(&req)
+	zone, err := h.repo.Create(r.Context(), &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -43,9 +44,9 @@ func (h *RestrictedZoneHandler) CreateRestrictedZone(w http.ResponseWriter, r *h
 }
 
 func (h *RestrictedZoneHandler) GetAllRestrictedZones(w http.ResponseWriter, r *http.Request) {
-	zones, err := h.repo.GetAll()
+	zones, err := h.repo.GetAll(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -55,7 +56,7 @@ func (h *RestrictedZoneHandler) GetAllRestrictedZones(w http.ResponseWriter, r *
 
 func (h *RestrictedZoneHandler) UpdateRestrictedZone(w http.ResponseWriter, r *http.Request) {
 	var req models.UpdateRestrictedZoneRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequest(r, &req); err != nil {
 		http.Error(w, fmt.Sprintf("Ошибка декодирования JSON: %v", err), http.StatusBadRequest)
 		return
 	}
@@ -69,9 +70,9 @@ func (h *RestrictedZoneHandler) UpdateRestrictedZone(w http.ResponseWriter, r *h
 		return
 	}
 
-	zone, err := h.repo.Update(&req)
+	zone, err := h.repo.Update(r.Context(), &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -100,8 +101,8 @@ func (h *RestrictedZoneHandler) UpdateRestrictedZone(w http.ResponseWriter, r *h
 
 	logMessage := fmt.Sprintf("Пользователь ID %d изменил запись ID %d. Изменения: %v", req.UserID, req.ID, changes)
 
-	if err := h.repo.CreateActivityLog(req.UserID, logMessage); err != nil {
-		fmt.Printf("Ошибка создания лога активности: %v\n", err)
+	if err := h.repo.CreateActivityLog(r.Context(), req.UserID, logMessage); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка создания лога активности", "error", err)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
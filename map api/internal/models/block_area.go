@@ -2,6 +2,8 @@ package models
 
 import "time"
 
+const EventZoneCreated = "zone.created"
+
 type BlockArea struct {
 	ID        int        `json:"id" db:"id"`
 	UserID    int        `json:"user_id" db:"user_id"`
@@ -17,7 +19,7 @@ type BlockArea struct {
 }
 
 type CreateBlockAreaRequest struct {
-	UserID    int        `json:"user_id"`
+	Caller
 	Name      string     `json:"name"`
 	Radius    float64    `json:"radius"`
 	Latitude  float64    `json:"latitude"`
@@ -28,8 +30,8 @@ type CreateBlockAreaRequest struct {
 }
 
 type UpdateBlockAreaRequest struct {
-	ID        int        `json:"id"`
-	UserID    int        `json:"user_id"`
+	ID int `json:"id"`
+	Caller
 	Name      *string    `json:"name,omitempty"`
 	Radius    *float64   `json:"radius,omitempty"`
 	Latitude  *float64   `json:"latitude,omitempty"`
@@ -17,7 +17,7 @@ type RestrictedZone struct {
 }
 
 type CreateRestrictedZoneRequest struct {
-	UserID        int     `json:"user_id"`
+	Caller
 	Height        float64 `json:"height"`
 	Radius        float64 `json:"radius"`
 	DurationHours int     `json:"duration_hours"`
@@ -28,8 +28,8 @@ type CreateRestrictedZoneRequest struct {
 }
 
 type UpdateRestrictedZoneRequest struct {
-	ID            int      `json:"id"`
-	UserID        int      `json:"user_id"`
+	ID int `json:"id"`
+	Caller
 	Height        *float64 `json:"height,omitempty"`
 	Radius        *float64 `json:"radius,omitempty"`
 	DurationHours *int     `json:"duration_hours,omitempty"`
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"map-api/internal/models"
 	"strings"
@@ -16,7 +18,7 @@ func NewBlockAreaRepository(db *sql.DB) *BlockAreaRepository {
 	return &BlockAreaRepository{db: db}
 }
 
-func (r *BlockAreaRepository) Create(area *models.CreateBlockAreaRequest) (*models.BlockArea, error) {
+func (r *BlockAreaRepository) Create(ctx context.Context, area *models.CreateBlockAreaRequest) (*models.BlockArea, error) {
 	query := `
         INSERT INTO block_areas (user_id, name, radius, latitude, longitude, altitude, state, expires_at)
         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
@@ -25,7 +27,7 @@ func (r *BlockAreaRepository) Create(area *models.CreateBlockAreaRequest) (*mode
 	var id int
 	var createdAt, updatedAt time.Time
 
-	err := r.db.QueryRow(query, area.UserID, area.Name, area.Radius,
+	err := r.db.QueryRowContext(ctx, query, area.UserID, area.Name, area.Radius,
 		area.Latitude, area.Longitude, area.Altitude, area.State, area.ExpiresAt).Scan(&id, &createdAt, &updatedAt)
 
 	if err != nil {
@@ -47,13 +49,13 @@ func (r *BlockAreaRepository) Create(area *models.CreateBlockAreaRequest) (*mode
 	}, nil
 }
 
-func (r *BlockAreaRepository) GetAll() ([]models.BlockArea, error) {
+func (r *BlockAreaRepository) GetAll(ctx context.Context) ([]models.BlockArea, error) {
 	query := `
         SELECT id, user_id, name, radius, latitude, longitude, altitude, state, expires_at, created_at, updated_at
         FROM block_areas
         ORDER BY created_at DESC`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения запретных зон: %v", err)
 	}
@@ -73,7 +75,19 @@ func (r *BlockAreaRepository) GetAll() ([]models.BlockArea, error) {
 	return areas, nil
 }
 
-func (r *BlockAreaRepository) Update(req *models.UpdateBlockAreaRequest) (*models.BlockArea, error) {
+// CountActive - число действующих запретных зон.
+func (r *BlockAreaRepository) CountActive(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM block_areas
+        WHERE state = 'active' AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчета запретных зон: %v", err)
+	}
+	return count, nil
+}
+
+func (r *BlockAreaRepository) Update(ctx context.Context, req *models.UpdateBlockAreaRequest) (*models.BlockArea, error) {
 	setParts := []string{}
 	args := []interface{}{}
 	argIndex := 1
@@ -132,7 +146,7 @@ func (r *BlockAreaRepository) Update(req *models.UpdateBlockAreaRequest) (*model
 		strings.Join(setParts, ", "), argIndex)
 
 	var area models.BlockArea
-	err := r.db.QueryRow(query, args...).Scan(
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
 		&area.ID, &area.UserID, &area.Name, &area.Radius,
 		&area.Latitude, &area.Longitude, &area.Altitude, &area.State, &area.ExpiresAt, &area.CreatedAt, &area.UpdatedAt)
 
@@ -146,13 +160,31 @@ func (r *BlockAreaRepository) Update(req *models.UpdateBlockAreaRequest) (*model
 	return &area, nil
 }
 
-func (r *BlockAreaRepository) CreateActivityLog(userID int, logMessage string) error {
+func (r *BlockAreaRepository) CreateActivityLog(ctx context.Context, userID int, logMessage string) error {
 	query := `INSERT INTO activitylogs (user_id, logs) VALUES ($1, $2)`
 
-	_, err := r.db.Exec(query, userID, logMessage)
+	_, err := r.db.ExecContext(ctx, query, userID, logMessage)
 	if err != nil {
 		return fmt.Errorf("ошибка создания лога активности: %v", err)
 	}
 
 	return nil
 }
+
+// CreateEvent ставит событие в общую очередь notification_events, из которой
+// police api рассылает уведомления и вебхуки.
+func (r *BlockAreaRepository) CreateEvent(ctx context.Context, userID int, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации события: %v", err)
+	}
+
+	query := `INSERT INTO notification_events (user_id, event_type, payload) VALUES ($1, $2, $3)`
+
+	_, err = r.db.ExecContext(ctx, query, userID, eventType, data)
+	if err != nil {
+		return fmt.Errorf("ошибка создания события: %v", err)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"map-api/internal/models"
@@ -16,7 +17,7 @@ func NewRestrictedZoneRepository(db *sql.DB) *RestrictedZoneRepository {
 	return &RestrictedZoneRepository{db: db}
 }
 
-func (r *RestrictedZoneRepository) Create(zone *models.CreateRestrictedZoneRequest) (*models.RestrictedZone, error) {
+func (r *RestrictedZoneRepository) Create(ctx context.Context, zone *models.CreateRestrictedZoneRequest) (*models.RestrictedZone, error) {
 	query := `
         INSERT INTO restricted_zones (user_id, height, radius, duration_hours, latitude, longitude, altitude, state)
         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
@@ -25,7 +26,7 @@ func (r *RestrictedZoneRepository) Create(zone *models.CreateRestrictedZoneReque
 	var id int
 	var createdAt, updatedAt time.Time
 
-	err := r.db.QueryRow(query, zone.UserID, zone.Height, zone.Radius, zone.DurationHours,
+	err := r.db.QueryRowContext(ctx, query, zone.UserID, zone.Height, zone.Radius, zone.DurationHours,
 		zone.Latitude, zone.Longitude, zone.Altitude, zone.State).Scan(&id, &createdAt, &updatedAt)
 
 	if err != nil {
@@ -47,13 +48,13 @@ func (r *RestrictedZoneRepository) Create(zone *models.CreateRestrictedZoneReque
 	}, nil
 }
 
-func (r *RestrictedZoneRepository) GetAll() ([]models.RestrictedZone, error) {
+func (r *RestrictedZoneRepository) GetAll(ctx context.Context) ([]models.RestrictedZone, error) {
 	query := `
         SELECT id, user_id, height, radius, duration_hours, latitude, longitude, altitude, state, created_at, updated_at
         FROM restricted_zones
         ORDER BY created_at DESC`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения запретных зон: %v", err)
 	}
@@ -73,7 +74,7 @@ func (r *RestrictedZoneRepository) GetAll() ([]models.RestrictedZone, error) {
 	return zones, nil
 }
 
-func (r *RestrictedZoneRepository) Update(req *models.UpdateRestrictedZoneRequest) (*models.RestrictedZone, error) {
+func (r *RestrictedZoneRepository) Update(ctx context.Context, req *models.UpdateRestrictedZoneRequest) (*models.RestrictedZone, error) {
 	setParts := []string{}
 	args := []interface{}{}
 	argIndex := 1
@@ -132,7 +133,7 @@ func (r *RestrictedZoneRepository) Update(req *models.UpdateRestrictedZoneReques
 		strings.Join(setParts, ", "), argIndex)
 
 	var zone models.RestrictedZone
-	err := r.db.QueryRow(query, args...).Scan(
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
 		&zone.ID, &zone.UserID, &zone.Height, &zone.Radius, &zone.DurationHours,
 		&zone.Latitude, &zone.Longitude, &zone.Altitude, &zone.State, &zone.CreatedAt, &zone.UpdatedAt)
 
@@ -146,10 +147,10 @@ func (r *RestrictedZoneRepository) Update(req *models.UpdateRestrictedZoneReques
 	return &zone, nil
 }
 
-func (r *RestrictedZoneRepository) CreateActivityLog(userID int, logMessage string) error {
+func (r *RestrictedZoneRepository) CreateActivityLog(ctx context.Context, userID int, logMessage string) error {
 	query := `INSERT INTO activitylogs (user_id, logs) VALUES ($1, $2)`
 
-	_, err := r.db.Exec(query, userID, logMessage)
+	_, err := r.db.ExecContext(ctx, query, userID, logMessage)
 	if err != nil {
 		return fmt.Errorf("ошибка создания лога активности: %v", err)
 	}
@@ -1,29 +1,85 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"common/gatewayauth"
+	"common/health"
+	"common/logging"
+	"common/metrics"
+	"common/requestid"
+	"common/tracing"
 	"map-api/internal/config"
 	"map-api/internal/handlers"
 	"map-api/internal/repository"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
 func main() {
-	db, err := config.NewDatabaseConnection()
+	configFile := flag.String("config", "", "файл с переменными окружения (по умолчанию .env, если есть)")
+	printConfig := flag.Bool("print-config", false, "вывести конфигурацию без секретов и выйти")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка конфигурации:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("map", cfg.LogLevel); err != nil {
+		logging.Fatal("Ошибка настройки логирования", "error", err)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "map", cfg.TracesExporter)
+	if err != nil {
+		logging.Fatal("Ошибка настройки трассировки", "error", err)
+	}
+
+	db, err := config.NewDatabaseConnection(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatal("Ошибка подключения к базе данных:", err)
+		logging.Fatal("Ошибка подключения к базе данных", "error", err)
 	}
 	defer db.Close()
 
 	blockAreaRepo := repository.NewBlockAreaRepository(db)
 	blockAreaHandler := handlers.NewBlockAreaHandler(blockAreaRepo)
 
+	metrics.RegisterDB(db, "map")
+	metrics.RegisterGauge("map_active_block_areas", "Число действующих запретных зон.", func() float64 {
+		count, err := blockAreaRepo.CountActive(context.Background())
+		if err != nil {
+			slog.Error("Ошибка подсчета запретных зон для метрик", "error", err)
+			return math.NaN()
+		}
+		return float64(count)
+	})
+
 	router := mux.NewRouter()
+	router.Use(otelmux.Middleware("map"), requestid.Middleware, metrics.Middleware)
+
+	healthHandler := health.New("map")
+	healthHandler.AddCheck("database", db.PingContext)
+	router.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	api := router.PathPrefix("/api").Subrouter()
+	// Принимаются только запросы, подписанные шлюзом
+	api.Use(gatewayauth.NewVerifier(cfg.GatewayJWKSURL, nil).Middleware)
 	api.HandleFunc("/map/create", blockAreaHandler.CreateBlockArea).Methods("POST")
 	api.HandleFunc("/map/update", blockAreaHandler.UpdateBlockArea).Methods("POST")
 	api.HandleFunc("/map", blockAreaHandler.GetAllBlockAreas).Methods("GET")
@@ -44,6 +100,33 @@ func main() {
 		})
 	})
 
-	log.Println("Сервер запущен на порту 8082...")
-	log.Fatal(http.ListenAndServe(":8082", router))
+	// SIGTERM посылают оркестраторы, SIGINT - Ctrl+C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	slog.Info("Сервер запущен", "port", cfg.Port)
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("Ошибка запуска сервера", "error", err)
+	case <-ctx.Done():
+	}
+
+	// Остановка: новые соединения не принимаются, начатые запросы дорабатывают.
+	slog.Info("Остановка сервера", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Не все запросы завершились до остановки", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Ошибка отправки спанов", "error", err)
+	}
+	slog.Info("Сервер остановлен")
 }
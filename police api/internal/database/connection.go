@@ -3,7 +3,9 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+
+	"common/tracing"
 
 	_ "github.com/lib/pq"
 )
@@ -12,17 +14,8 @@ type DB struct {
 	*sql.DB
 }
 
-func NewConnection() (*DB, error) {
-	dbHost := "aws-0-us-east-2.pooler.supabase.com"
-	dbPort := 5432
-	dbUser := "postgres.akodbsqofninasbpqxbx"
-	dbPassword := "HgO3lFR752WPNGN"
-	dbName := "postgres"
-
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=require",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
-
-	db, err := sql.Open("postgres", psqlInfo)
+func NewConnection(databaseURL string) (*DB, error) {
+	db, err := tracing.OpenDB("postgres", databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка подключения к базе данных: %v", err)
 	}
@@ -31,7 +24,7 @@ func NewConnection() (*DB, error) {
 		return nil, fmt.Errorf("ошибка ping базы данных: %v", err)
 	}
 
-	log.Println("Успешное подключение к базе данных PostgreSQL")
+	slog.Info("Успешное подключение к базе данных PostgreSQL")
 	return &DB{db}, nil
 }
 
@@ -56,7 +49,7 @@ func (db *DB) CreateTables() error {
 		return fmt.Errorf("ошибка создания таблицы flightrequest: %v", err)
 	}
 
-	log.Println("Таблица flightrequest создана успешно")
+	slog.Info("Таблица создана успешно", "table", "flightrequest")
 
 	activityLogsQuery := `
 	CREATE TABLE IF NOT EXISTS activitylogs (
@@ -70,7 +63,113 @@ func (db *DB) CreateTables() error {
 	if err != nil {
 		return fmt.Errorf("ошибка создания таблицы activitylogs: %v", err)
 	}
-	log.Println("Таблица activitylogs создана успешно")
+	slog.Info("Таблица создана успешно", "table", "activitylogs")
+
+	notificationEventsQuery := `
+	CREATE TABLE IF NOT EXISTS notification_events (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload JSONB NOT NULL DEFAULT '{}',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		locked_until TIMESTAMP,
+		processed_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE notification_events ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE notification_events ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_notification_events_unprocessed
+		ON notification_events(id) WHERE processed_at IS NULL;`
+
+	_, err = db.Exec(notificationEventsQuery)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы notification_events: %v", err)
+	}
+	slog.Info("Таблица создана успешно", "table", "notification_events")
+
+	notificationsQuery := `
+	CREATE TABLE IF NOT EXISTS notifications (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		title TEXT NOT NULL,
+		message TEXT NOT NULL,
+		payload JSONB NOT NULL DEFAULT '{}',
+		event_id INTEGER,
+		read_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE notifications ADD COLUMN IF NOT EXISTS event_id INTEGER;
+	CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_event
+		ON notifications(event_id, user_id) WHERE event_id IS NOT NULL;`
+
+	_, err = db.Exec(notificationsQuery)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы notifications: %v", err)
+	}
+	slog.Info("Таблица создана успешно", "table", "notifications")
+
+	notificationPreferencesQuery := `
+	CREATE TABLE IF NOT EXISTS notification_preferences (
+		user_id INTEGER NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		channel VARCHAR(20) NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		target TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (user_id, event_type, channel)
+	);`
+
+	_, err = db.Exec(notificationPreferencesQuery)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы notification_preferences: %v", err)
+	}
+	slog.Info("Таблица создана успешно", "table", "notification_preferences")
+
+	webhookSubscriptionsQuery := `
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		secret VARCHAR(64) NOT NULL,
+		event_types TEXT[] NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_user_id ON webhook_subscriptions(user_id);`
+
+	_, err = db.Exec(webhookSubscriptionsQuery)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы webhook_subscriptions: %v", err)
+	}
+	slog.Info("Таблица создана успешно", "table", "webhook_subscriptions")
+
+	webhookDeliveriesQuery := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id SERIAL PRIMARY KEY,
+		subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_id INTEGER NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_status_code INTEGER,
+		last_error TEXT,
+		delivered_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending
+		ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event
+		ON webhook_deliveries(subscription_id, event_id);`
+
+	_, err = db.Exec(webhookDeliveriesQuery)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы webhook_deliveries: %v", err)
+	}
+	slog.Info("Таблица создана успешно", "table", "webhook_deliveries")
 
 	return nil
 }
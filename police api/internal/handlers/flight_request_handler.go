@@ -1,146 +1,141 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"police-api/internal/models"
+	"police-api/internal/notifications"
 	"police-api/internal/repository"
 )
 
 type FlightRequestHandler struct {
-	repo *repository.FlightRequestRepository
+	repo       *repository.FlightRequestRepository
+	droneRepo  *repository.DroneRepository
+	dispatcher *notifications.Dispatcher
 }
 
-func NewFlightRequestHandler(repo *repository.FlightRequestRepository) *FlightRequestHandler {
-	return &FlightRequestHandler{repo: repo}
+func NewFlightRequestHandler(repo *repository.FlightRequestRepository, droneRepo *repository.DroneRepository, dispatcher *notifications.Dispatcher) *FlightRequestHandler {
+	return &FlightRequestHandler{repo: repo, droneRepo: droneRepo, dispatcher: dispatcher}
 }
 
 func (h *FlightRequestHandler) CreateRequest(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateFlightRequestRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
 		return
 	}
 
 	// Валидация
-	if req.UserID <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
-		return
-	}
-
-	if req.DroneID <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "drone_id не найден")
+	fieldErrors, err := h.validateCreateRequest(r.Context(), &req)
+	if err != nil {
+		sendServerError(w, r, err)
 		return
 	}
 
-	if req.Altitude <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "altitude не найден")
+	if len(fieldErrors) > 0 {
+		sendValidationErrorResponse(w, fieldErrors)
 		return
 	}
 
-	flightRequest, err := h.repo.Create(&req)
+	flightRequest, err := h.repo.Create(r.Context(), &req)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		sendServerError(w, r, err)
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusCreated, flightRequest)
+	h.notifyStateChange(r.Context(), models.EventRequestCreated, flightRequest)
+
+	sendSuccessResponse(w, http.StatusCreated, flightRequest)
 }
 
 func (h *FlightRequestHandler) GetPendingRequests(w http.ResponseWriter, r *http.Request) {
-	requests, err := h.repo.GetPendingRequests()
+	requests, err := h.repo.GetPendingRequests(r.Context())
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		sendServerError(w, r, err)
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusOK, requests)
+	sendSuccessResponse(w, http.StatusOK, requests)
 }
 
 func (h *FlightRequestHandler) GetUserRequests(w http.ResponseWriter, r *http.Request) {
 	var req models.UserRequestsRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
 		return
 	}
 
 	if req.UserID <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
 		return
 	}
 
-	requests, err := h.repo.GetUserRequests(req.UserID)
+	requests, err := h.repo.GetUserRequests(r.Context(), req.UserID)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		sendServerError(w, r, err)
 		return
 	}
 
-	h.sendSuccessResponse(w, http.StatusOK, requests)
+	sendSuccessResponse(w, http.StatusOK, requests)
 }
 
 func (h *FlightRequestHandler) UpdateRequestState(w http.ResponseWriter, r *http.Request) {
 	var updateReq models.UpdateFlightRequestRequest
-	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+	if err := decodeRequest(r, &updateReq); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
 		return
 	}
 
 	if updateReq.ID <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "id не найден")
+		sendErrorResponse(w, http.StatusBadRequest, "id не найден")
 		return
 	}
 
 	if updateReq.UserID <= 0 {
-		h.sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
 		return
 	}
 
 	if updateReq.State == "" {
-		h.sendErrorResponse(w, http.StatusBadRequest, "state не может быть пустым")
+		sendErrorResponse(w, http.StatusBadRequest, "state не может быть пустым")
 		return
 	}
 
-	if err := h.repo.UpdateState(updateReq.ID, updateReq.State); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+	flightRequest, err := h.repo.UpdateState(r.Context(), updateReq.ID, updateReq.State)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	logMessage := fmt.Sprintf("Пользователь ID %d изменил заявку ID %d на статус '%s'", updateReq.UserID, updateReq.ID, updateReq.State)
-	if err := h.repo.CreateActivityLog(updateReq.UserID, logMessage); err != nil {
-		fmt.Printf("Ошибка создания лога активности: %v\n", err)
+	if err := h.repo.CreateActivityLog(r.Context(), updateReq.UserID, logMessage); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка создания лога активности", "error", err)
 	}
 
-	h.sendSuccessResponse(w, http.StatusOK, map[string]interface{}{
+	h.notifyStateChange(r.Context(), stateEvents[flightRequest.State], flightRequest)
+
+	sendSuccessResponse(w, http.StatusOK, map[string]interface{}{
 		"id":      updateReq.ID,
 		"state":   updateReq.State,
 		"message": "Статус заявки успешно обновлен",
 	})
 }
 
-func (h *FlightRequestHandler) sendSuccessResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
-	response := models.APIResponse{
-		Success: true,
-		Data:    data,
-	}
-
-	json.NewEncoder(w).Encode(response)
+var stateEvents = map[string]string{
+	"approved": models.EventRequestApproved,
+	"denied":   models.EventRequestDenied,
+	"revoked":  models.EventRequestRevoked,
 }
 
-func (h *FlightRequestHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, errorMessage string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
-	response := models.APIResponse{
-		Success: false,
-		Error:   errorMessage,
-	}
-
-	json.NewEncoder(w).Encode(response)
+func (h *FlightRequestHandler) notifyStateChange(ctx context.Context, eventType string, fr *models.FlightRequest) {
+	h.dispatcher.Emit(ctx, fr.UserID, eventType, map[string]interface{}{
+		"request_id": fr.ID,
+		"drone_id":   fr.DroneID,
+		"state":      fr.State,
+	})
 }
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+
+	"police-api/internal/models"
+	"police-api/internal/netguard"
+	"police-api/internal/repository"
+)
+
+type NotificationHandler struct {
+	repo *repository.NotificationRepository
+}
+
+func NewNotificationHandler(repo *repository.NotificationRepository) *NotificationHandler {
+	return &NotificationHandler{repo: repo}
+}
+
+func (h *NotificationHandler) GetInbox(w http.ResponseWriter, r *http.Request) {
+	var req models.InboxRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	if req.UserID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		return
+	}
+
+	notifications, err := h.repo.GetInbox(r.Context(), req.UserID, req.UnreadOnly)
+	if err != nil {
+		sendServerError(w, r, err)
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, notifications)
+}
+
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	var req models.MarkNotificationReadRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	if req.UserID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		return
+	}
+
+	if req.ID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "id не найден")
+		return
+	}
+
+	if err := h.repo.MarkRead(r.Context(), req.UserID, req.ID); err != nil {
+		sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, map[string]interface{}{
+		"id":      req.ID,
+		"message": "Уведомление отмечено как прочитанное",
+	})
+}
+
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	var req models.NotificationPreferencesRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	if req.UserID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		return
+	}
+
+	prefs, err := h.repo.GetPreferences(r.Context(), req.UserID)
+	if err != nil {
+		sendServerError(w, r, err)
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, prefs)
+}
+
+func (h *NotificationHandler) UpdatePreference(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateNotificationPreferenceRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	var fieldErrors []models.FieldError
+	if req.UserID <= 0 {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "user_id", Message: "user_id не найден"})
+	}
+	if !contains(models.EventTypes, req.EventType) {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "event_type", Message: "неизвестный тип события"})
+	}
+	if !contains(models.Channels, req.Channel) {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "channel", Message: "неизвестный канал доставки"})
+	}
+	if req.Channel == models.ChannelWebhook && req.Enabled {
+		if req.Target == "" {
+			fieldErrors = append(fieldErrors, models.FieldError{Field: "target", Message: "для вебхука требуется URL"})
+		} else if err := netguard.CheckURL(r.Context(), req.Target); err != nil {
+			fieldErrors = append(fieldErrors, models.FieldError{Field: "target", Message: err.Error()})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		sendValidationErrorResponse(w, fieldErrors)
+		return
+	}
+
+	pref := models.NotificationPreference{
+		UserID:    req.UserID,
+		EventType: req.EventType,
+		Channel:   req.Channel,
+		Enabled:   req.Enabled,
+		Target:    req.Target,
+	}
+
+	if err := h.repo.UpsertPreference(r.Context(), &pref); err != nil {
+		sendServerError(w, r, err)
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, pref)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"common/requestid"
+	"police-api/internal/models"
+)
+
+func sendSuccessResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := models.APIResponse{
+		Success: true,
+		Data:    data,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func sendValidationErrorResponse(w http.ResponseWriter, fieldErrors []models.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	response := models.APIResponse{
+		Success:   false,
+		Error:     "Ошибка валидации заявки",
+		Errors:    fieldErrors,
+		RequestID: w.Header().Get(requestid.Header),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendServerError пишет ошибку в лог с полями запроса и отвечает 500.
+func sendServerError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "Ошибка обработки запроса", "error", err)
+	sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+}
+
+func sendErrorResponse(w http.ResponseWriter, statusCode int, errorMessage string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := models.APIResponse{
+		Success:   false,
+		Error:     errorMessage,
+		RequestID: w.Header().Get(requestid.Header),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// WriteError отвечает ошибкой в формате API сервиса. Нужна middleware вне
+// пакета, например проверке подписи шлюза.
+func WriteError(w http.ResponseWriter, statusCode int, message string) {
+	sendErrorResponse(w, statusCode, message)
+}
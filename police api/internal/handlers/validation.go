@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"police-api/internal/models"
+)
+
+// Предельная высота полета для гражданских дронов, м.
+const maxFlightAltitude = 120.0
+
+func (h *FlightRequestHandler) validateCreateRequest(ctx context.Context, req *models.CreateFlightRequestRequest) ([]models.FieldError, error) {
+	var errs []models.FieldError
+
+	if req.UserID <= 0 {
+		errs = append(errs, models.FieldError{Field: "user_id", Message: "user_id не найден"})
+	}
+
+	if req.Altitude <= 0 || req.Altitude > maxFlightAltitude {
+		errs = append(errs, models.FieldError{
+			Field:   "altitude",
+			Message: fmt.Sprintf("altitude должна быть в диапазоне (0, %.0f] м", maxFlightAltitude),
+		})
+	}
+
+	errs = append(errs, validateLat("start_lat", req.StartLat)...)
+	errs = append(errs, validateLng("start_lng", req.StartLng)...)
+	errs = append(errs, validateLat("end_lat", req.EndLat)...)
+	errs = append(errs, validateLng("end_lng", req.EndLng)...)
+
+	if req.DepartureTime.IsZero() {
+		errs = append(errs, models.FieldError{Field: "departure_time", Message: "departure_time обязательно"})
+	} else if !req.DepartureTime.After(time.Now()) {
+		errs = append(errs, models.FieldError{Field: "departure_time", Message: "departure_time должно быть в будущем"})
+	}
+
+	if req.DroneID <= 0 {
+		errs = append(errs, models.FieldError{Field: "drone_id", Message: "drone_id должен быть больше 0"})
+		return errs, nil
+	}
+
+	drone, err := h.droneRepo.GetForUser(ctx, req.DroneID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case drone == nil:
+		errs = append(errs, models.FieldError{Field: "drone_id", Message: "дрон не существует"})
+	case !drone.CanFly():
+		errs = append(errs, models.FieldError{Field: "drone_id", Message: "нет прав пилота для этого дрона"})
+	case drone.Decommissioned:
+		errs = append(errs, models.FieldError{Field: "drone_id", Message: "дрон выведен из эксплуатации"})
+	}
+
+	return errs, nil
+}
+
+func validateLat(field string, lat float64) []models.FieldError {
+	if lat < -90 || lat > 90 {
+		return []models.FieldError{{Field: field, Message: field + " должна быть в диапазоне [-90, 90]"}}
+	}
+	return nil
+}
+
+func validateLng(field string, lng float64) []models.FieldError {
+	if lng < -180 || lng > 180 {
+		return []models.FieldError{{Field: field, Message: field + " должна быть в диапазоне [-180, 180]"}}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"police-api/internal/models"
+	"police-api/internal/netguard"
+	"police-api/internal/repository"
+	"police-api/internal/webhooks"
+)
+
+type WebhookHandler struct {
+	repo *repository.WebhookRepository
+}
+
+func NewWebhookHandler(repo *repository.WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{repo: repo}
+}
+
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateWebhookRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	var fieldErrors []models.FieldError
+	if req.UserID <= 0 {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "user_id", Message: "user_id не найден"})
+	}
+	if err := netguard.CheckURL(r.Context(), req.URL); err != nil {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "url", Message: err.Error()})
+	}
+	if len(req.EventTypes) == 0 {
+		fieldErrors = append(fieldErrors, models.FieldError{Field: "event_types", Message: "укажите хотя бы один тип события"})
+	}
+	for _, eventType := range req.EventTypes {
+		if !contains(models.WebhookEventTypes, eventType) {
+			fieldErrors = append(fieldErrors, models.FieldError{Field: "event_types", Message: "неизвестный тип события: " + eventType})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		sendValidationErrorResponse(w, fieldErrors)
+		return
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка генерации секрета вебхука", "error", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Ошибка генерации секрета вебхука")
+		return
+	}
+
+	sub := models.WebhookSubscription{
+		UserID:     req.UserID,
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+	}
+
+	if err := h.repo.CreateSubscription(r.Context(), &sub); err != nil {
+		sendServerError(w, r, err)
+		return
+	}
+
+	// Секрет возвращается только при создании.
+	sendSuccessResponse(w, http.StatusCreated, sub)
+}
+
+func (h *WebhookHandler) GetUserWebhooks(w http.ResponseWriter, r *http.Request) {
+	var req models.UserWebhooksRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	if req.UserID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		return
+	}
+
+	subs, err := h.repo.GetUserSubscriptions(r.Context(), req.UserID)
+	if err != nil {
+		sendServerError(w, r, err)
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, subs)
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.DeleteWebhookRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	if req.UserID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		return
+	}
+
+	if req.ID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "id не найден")
+		return
+	}
+
+	if err := h.repo.DeleteSubscription(r.Context(), req.UserID, req.ID); err != nil {
+		sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, map[string]interface{}{
+		"id":      req.ID,
+		"message": "Вебхук удален",
+	})
+}
+
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	var req models.WebhookDeliveriesRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	if req.UserID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		return
+	}
+
+	if req.SubscriptionID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "subscription_id не найден")
+		return
+	}
+
+	deliveries, err := h.repo.GetDeliveries(r.Context(), req.UserID, req.SubscriptionID)
+	if err != nil {
+		sendServerError(w, r, err)
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	var req models.RedeliverWebhookRequest
+	if err := decodeRequest(r, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	if req.UserID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "user_id не найден")
+		return
+	}
+
+	if req.DeliveryID <= 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "delivery_id не найден")
+		return
+	}
+
+	delivery, err := h.repo.Redeliver(r.Context(), req.UserID, req.DeliveryID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	sendSuccessResponse(w, http.StatusCreated, delivery)
+}
@@ -0,0 +1,13 @@
+package models
+
+// Caller - пользователь, от имени которого выполняется запрос. Встраивается
+// в структуры запросов и заполняется из личности, подписанной шлюзом: из
+// JSON тела user_id не читается, иначе клиент мог бы выдать себя за другого
+// пользователя.
+type Caller struct {
+	UserID int `json:"-"`
+}
+
+func (c *Caller) SetCaller(caller Caller) {
+	*c = caller
+}
@@ -0,0 +1,23 @@
+package models
+
+// Роли участников организации, как их хранит drones api.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRolePilot  = "pilot"
+	OrgRoleViewer = "viewer"
+)
+
+type Drone struct {
+	ID      int `json:"id" db:"id"`
+	OwnerID int `json:"owner_id" db:"owner_id"`
+	// UserRole - роль запросившего пользователя по отношению к дрону: личный
+	// владелец считается owner, для дронов организации берется роль участника.
+	UserRole string `json:"-"`
+	// Decommissioned - дрон выведен из эксплуатации в drones api.
+	Decommissioned bool `json:"decommissioned"`
+}
+
+// CanFly сообщает, может ли пользователь подавать заявки на полет этого дрона.
+func (d *Drone) CanFly() bool {
+	return d.UserRole == OrgRoleOwner || d.UserRole == OrgRolePilot
+}
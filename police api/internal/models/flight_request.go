@@ -20,7 +20,7 @@ type FlightRequest struct {
 }
 
 type CreateFlightRequestRequest struct {
-	UserID        int       `json:"user_id"`
+	Caller
 	DroneID       int       `json:"drone_id"`
 	DepartureTime time.Time `json:"departure_time"`
 	Altitude      float64   `json:"altitude"`
@@ -31,13 +31,13 @@ type CreateFlightRequestRequest struct {
 }
 
 type UpdateFlightRequestRequest struct {
-	ID     int    `json:"id"`
-	UserID int    `json:"user_id"`
-	State  string `json:"state"`
+	ID int `json:"id"`
+	Caller
+	State string `json:"state"`
 }
 
 type UserRequestsRequest struct {
-	UserID int `json:"user_id"`
+	Caller
 }
 
 type ActivityLog struct {
@@ -47,8 +47,16 @@ type ActivityLog struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success bool         `json:"success"`
+	Data    interface{}  `json:"data,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	// RequestID заполняется в ответах с ошибкой для поиска запроса в логах.
+	RequestID string `json:"request_id,omitempty"`
 }
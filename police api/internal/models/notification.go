@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventRequestCreated  = "request.created"
+	EventRequestApproved = "request.approved"
+	EventRequestDenied   = "request.denied"
+	EventRequestRevoked  = "request.revoked"
+	EventGeofenceBreach  = "drone.geofence_breach"
+	EventPoliceStop      = "drone.police_stop"
+
+	EventDroneStatusChanged = "drone.status_changed"
+	EventZoneCreated        = "zone.created"
+)
+
+const (
+	ChannelInbox   = "inbox"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// EventTypes - события, о которых пользователь получает уведомления.
+var EventTypes = []string{
+	EventRequestCreated,
+	EventRequestApproved,
+	EventRequestDenied,
+	EventRequestRevoked,
+	EventGeofenceBreach,
+	EventPoliceStop,
+}
+
+var Channels = []string{ChannelInbox, ChannelEmail, ChannelWebhook}
+
+// NotificationEvent - запись в очереди событий notification_events.
+type NotificationEvent struct {
+	ID        int             `json:"id" db:"id"`
+	UserID    int             `json:"user_id" db:"user_id"`
+	EventType string          `json:"event_type" db:"event_type"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	// Attempts - номер текущей попытки обработки, считая эту.
+	Attempts  int       `json:"attempts" db:"attempts"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Notification - уведомление во внутреннем ящике пользователя.
+type Notification struct {
+	ID        int             `json:"id" db:"id"`
+	UserID    int             `json:"user_id" db:"user_id"`
+	EventType string          `json:"event_type" db:"event_type"`
+	Title     string          `json:"title" db:"title"`
+	Message   string          `json:"message" db:"message"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	// EventID - событие очереди, из которого создано уведомление. Повторная
+	// обработка события не создает второе уведомление.
+	EventID   int        `json:"-" db:"event_id"`
+	ReadAt    *time.Time `json:"read_at" db:"read_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type NotificationPreference struct {
+	UserID    int    `json:"user_id" db:"user_id"`
+	EventType string `json:"event_type" db:"event_type"`
+	Channel   string `json:"channel" db:"channel"`
+	Enabled   bool   `json:"enabled" db:"enabled"`
+	Target    string `json:"target,omitempty" db:"target"`
+}
+
+type InboxRequest struct {
+	Caller
+	UnreadOnly bool `json:"unread_only"`
+}
+
+type MarkNotificationReadRequest struct {
+	Caller
+	ID int `json:"id"`
+}
+
+type NotificationPreferencesRequest struct {
+	Caller
+}
+
+type UpdateNotificationPreferenceRequest struct {
+	Caller
+	EventType string `json:"event_type"`
+	Channel   string `json:"channel"`
+	Enabled   bool   `json:"enabled"`
+	Target    string `json:"target"`
+}
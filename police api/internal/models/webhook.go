@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+// WebhookEventTypes - события, на которые можно подписать вебхук.
+var WebhookEventTypes = []string{
+	EventRequestCreated,
+	EventRequestApproved,
+	EventRequestDenied,
+	EventRequestRevoked,
+	EventGeofenceBreach,
+	EventPoliceStop,
+	EventDroneStatusChanged,
+	EventZoneCreated,
+}
+
+type WebhookSubscription struct {
+	ID         int       `json:"id" db:"id"`
+	UserID     int       `json:"user_id" db:"user_id"`
+	URL        string    `json:"url" db:"url"`
+	Secret     string    `json:"secret,omitempty" db:"secret"`
+	EventTypes []string  `json:"event_types" db:"event_types"`
+	Active     bool      `json:"active" db:"active"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int             `json:"id" db:"id"`
+	SubscriptionID int             `json:"subscription_id" db:"subscription_id"`
+	EventID        int             `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code" db:"last_status_code"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
+type CreateWebhookRequest struct {
+	Caller
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+type UserWebhooksRequest struct {
+	Caller
+}
+
+type DeleteWebhookRequest struct {
+	Caller
+	ID int `json:"id"`
+}
+
+type WebhookDeliveriesRequest struct {
+	Caller
+	SubscriptionID int `json:"subscription_id"`
+}
+
+type RedeliverWebhookRequest struct {
+	Caller
+	DeliveryID int `json:"delivery_id"`
+}
+
+// PendingWebhookDelivery - доставка, готовая к отправке, вместе с адресом и секретом подписки.
+type PendingWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
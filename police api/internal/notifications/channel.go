@@ -0,0 +1,30 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Recipient - адресат уведомления для конкретного канала.
+type Recipient struct {
+	UserID int
+	Email  string
+	// Target - адрес доставки из настроек пользователя (например, URL вебхука).
+	Target string
+}
+
+type Message struct {
+	// EventID - событие очереди. При повторной обработке события каналы
+	// могут по нему отличить уже доставленное уведомление.
+	EventID   int
+	EventType string
+	Title     string
+	Text      string
+	Payload   json.RawMessage
+}
+
+// Channel - способ доставки уведомлений.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, to Recipient, msg Message) error
+}
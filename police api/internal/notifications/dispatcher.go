@@ -0,0 +1,178 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"common/logging"
+	"police-api/internal/models"
+	"police-api/internal/repository"
+)
+
+const (
+	batchSize = 50
+	// claimLease - время на обработку события. Если его не хватило, событие
+	// будет выдано снова; тот же интервал служит задержкой перед повтором.
+	claimLease  = time.Minute
+	maxAttempts = 5
+)
+
+// EventHandler получает каждое событие очереди помимо пользовательских уведомлений.
+type EventHandler interface {
+	HandleEvent(ctx context.Context, event models.NotificationEvent)
+}
+
+// Dispatcher разбирает очередь notification_events и доставляет каждое
+// событие по каналам, включенным в настройках пользователя. Очередь общая
+// для всех сервисов: drones api пишет в нее события о дронах напрямую.
+// Доставка - не менее одного раза: событие отмечается обработанным только
+// после успешной доставки по всем каналам, иначе повторяется после claimLease.
+type Dispatcher struct {
+	repo     *repository.NotificationRepository
+	channels map[string]Channel
+	handlers []EventHandler
+	interval time.Duration
+}
+
+func NewDispatcher(repo *repository.NotificationRepository, interval time.Duration, channels ...Channel) *Dispatcher {
+	d := &Dispatcher{
+		repo:     repo,
+		channels: make(map[string]Channel),
+		interval: interval,
+	}
+	for _, ch := range channels {
+		d.channels[ch.Name()] = ch
+	}
+	return d
+}
+
+func (d *Dispatcher) AddHandler(h EventHandler) {
+	d.handlers = append(d.handlers, h)
+}
+
+// Emit ставит событие в очередь на доставку.
+func (d *Dispatcher) Emit(ctx context.Context, userID int, eventType string, payload interface{}) {
+	if err := d.repo.CreateEvent(ctx, userID, eventType, payload); err != nil {
+		slog.ErrorContext(ctx, "Ошибка постановки уведомления в очередь", "event_type", eventType, "error", err)
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Начатая пачка доводится до конца и при остановке сервиса.
+			d.processBatch(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+func (d *Dispatcher) processBatch(ctx context.Context) {
+	events, err := d.repo.ClaimEvents(ctx, batchSize, claimLease)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка чтения очереди уведомлений", "error", err)
+		return
+	}
+
+	for _, event := range events {
+		d.process(logging.With(ctx, "event_id", event.ID, "user_id", event.UserID), event)
+	}
+}
+
+func (d *Dispatcher) process(ctx context.Context, event models.NotificationEvent) {
+	var err error
+	if isNotifiable(event.EventType) {
+		err = d.deliver(ctx, event)
+	}
+	for _, h := range d.handlers {
+		h.HandleEvent(ctx, event)
+	}
+
+	if err != nil {
+		if event.Attempts < maxAttempts {
+			slog.WarnContext(ctx, "Событие будет доставлено повторно", "attempt", event.Attempts, "error", err)
+			return
+		}
+		slog.ErrorContext(ctx, "Событие не доставлено, попытки исчерпаны", "attempt", event.Attempts, "error", err)
+	}
+
+	if err := d.repo.MarkEventProcessed(ctx, event.ID); err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения результата обработки события", "error", err)
+	}
+}
+
+// deliver отправляет уведомление по всем включенным каналам и возвращает
+// ошибки каналов, по которым доставить не удалось.
+func (d *Dispatcher) deliver(ctx context.Context, event models.NotificationEvent) error {
+	prefs, err := d.repo.GetPreferences(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+
+	msg := render(event)
+
+	var errs []error
+	for _, route := range resolveRoutes(prefs, event.EventType) {
+		ch, ok := d.channels[route.Channel]
+		if !ok {
+			continue
+		}
+
+		to := Recipient{UserID: event.UserID, Target: route.Target}
+		if route.Channel == models.ChannelEmail && to.Target == "" {
+			to.Email, err = d.repo.GetUserEmail(ctx, event.UserID)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		if err := ch.Send(ctx, to, msg); err != nil {
+			slog.ErrorContext(ctx, "Ошибка доставки уведомления", "channel", route.Channel, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// resolveRoutes возвращает включенные каналы для события. Если пользователь
+// ничего не настраивал для канала, действует значение по умолчанию:
+// внутренний ящик включен, email и вебхук выключены.
+func resolveRoutes(prefs []models.NotificationPreference, eventType string) []models.NotificationPreference {
+	configured := make(map[string]models.NotificationPreference)
+	for _, p := range prefs {
+		if p.EventType == eventType {
+			configured[p.Channel] = p
+		}
+	}
+
+	var routes []models.NotificationPreference
+	for _, channel := range models.Channels {
+		p, ok := configured[channel]
+		if !ok {
+			p = models.NotificationPreference{EventType: eventType, Channel: channel, Enabled: channel == models.ChannelInbox}
+		}
+		if p.Enabled {
+			routes = append(routes, p)
+		}
+	}
+
+	return routes
+}
+
+func isNotifiable(eventType string) bool {
+	for _, t := range models.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+
+	"police-api/internal/models"
+)
+
+// EmailChannel отправляет уведомления через SMTP. Для локальной разработки
+// достаточно заглушки вроде MailHog на localhost:1025.
+type EmailChannel struct {
+	addr string
+	from string
+}
+
+func NewEmailChannel(addr, from string) *EmailChannel {
+	return &EmailChannel{addr: addr, from: from}
+}
+
+func (c *EmailChannel) Name() string {
+	return models.ChannelEmail
+}
+
+func (c *EmailChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	address := to.Target
+	if address == "" {
+		address = to.Email
+	}
+	if address == "" {
+		return fmt.Errorf("у пользователя ID %d не указан email", to.UserID)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", c.from)
+	fmt.Fprintf(&body, "To: %s\r\n", address)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(msg.Text)
+	body.WriteString("\r\n")
+
+	if err := smtp.SendMail(c.addr, nil, c.from, []string{address}, []byte(body.String())); err != nil {
+		return fmt.Errorf("ошибка отправки email: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package notifications
+
+import (
+	"context"
+
+	"police-api/internal/models"
+	"police-api/internal/repository"
+)
+
+// InboxChannel сохраняет уведомления во внутренний ящик пользователя.
+type InboxChannel struct {
+	repo *repository.NotificationRepository
+}
+
+func NewInboxChannel(repo *repository.NotificationRepository) *InboxChannel {
+	return &InboxChannel{repo: repo}
+}
+
+func (c *InboxChannel) Name() string {
+	return models.ChannelInbox
+}
+
+func (c *InboxChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	return c.repo.CreateNotification(ctx, &models.Notification{
+		UserID:    to.UserID,
+		EventType: msg.EventType,
+		Title:     msg.Title,
+		Message:   msg.Text,
+		Payload:   msg.Payload,
+		EventID:   msg.EventID,
+	})
+}
@@ -0,0 +1,48 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"police-api/internal/models"
+)
+
+type eventPayload struct {
+	RequestID int    `json:"request_id"`
+	DroneID   int    `json:"drone_id"`
+	AreaID    int    `json:"area_id"`
+	AreaName  string `json:"area_name"`
+}
+
+func render(event models.NotificationEvent) Message {
+	var p eventPayload
+	json.Unmarshal(event.Payload, &p)
+
+	msg := Message{EventID: event.ID, EventType: event.EventType, Payload: event.Payload}
+
+	switch event.EventType {
+	case models.EventRequestCreated:
+		msg.Title = "Заявка на полет создана"
+		msg.Text = fmt.Sprintf("Заявка ID %d на полет дрона ID %d принята на рассмотрение.", p.RequestID, p.DroneID)
+	case models.EventRequestApproved:
+		msg.Title = "Заявка на полет одобрена"
+		msg.Text = fmt.Sprintf("Заявка ID %d на полет дрона ID %d одобрена.", p.RequestID, p.DroneID)
+	case models.EventRequestDenied:
+		msg.Title = "Заявка на полет отклонена"
+		msg.Text = fmt.Sprintf("Заявка ID %d на полет дрона ID %d отклонена.", p.RequestID, p.DroneID)
+	case models.EventRequestRevoked:
+		msg.Title = "Разрешение на полет отозвано"
+		msg.Text = fmt.Sprintf("Одобрение заявки ID %d на полет дрона ID %d отозвано.", p.RequestID, p.DroneID)
+	case models.EventGeofenceBreach:
+		msg.Title = "Нарушение запретной зоны"
+		msg.Text = fmt.Sprintf("Дрон ID %d вошел в запретную зону «%s» (ID %d).", p.DroneID, p.AreaName, p.AreaID)
+	case models.EventPoliceStop:
+		msg.Title = "Дрон остановлен полицией"
+		msg.Text = fmt.Sprintf("Дрон ID %d остановлен сотрудником полиции.", p.DroneID)
+	default:
+		msg.Title = "Уведомление"
+		msg.Text = fmt.Sprintf("Событие %s.", event.EventType)
+	}
+
+	return msg
+}
@@ -0,0 +1,62 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"police-api/internal/models"
+	"police-api/internal/netguard"
+)
+
+// WebhookChannel отправляет уведомление POST-запросом на URL из настроек
+// пользователя. Адреса во внутренней сети отклоняются при соединении.
+type WebhookChannel struct {
+	client *http.Client
+}
+
+func NewWebhookChannel() *WebhookChannel {
+	return &WebhookChannel{client: netguard.NewClient(5 * time.Second)}
+}
+
+func (c *WebhookChannel) Name() string {
+	return models.ChannelWebhook
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	if to.Target == "" {
+		return fmt.Errorf("у пользователя ID %d не указан URL вебхука", to.UserID)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"user_id":    to.UserID,
+		"event_type": msg.EventType,
+		"title":      msg.Title,
+		"message":    msg.Text,
+		"payload":    msg.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации вебхука: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to.Target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса вебхука: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки вебхука: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("вебхук вернул статус %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"police-api/internal/database"
+	"police-api/internal/models"
+)
+
+type DroneRepository struct {
+	db *database.DB
+}
+
+func NewDroneRepository(db *database.DB) *DroneRepository {
+	return &DroneRepository{db: db}
+}
+
+// GetForUser возвращает дрон с ролью пользователя userID по отношению к нему
+// или nil, если дрона с таким ID нет.
+func (r *DroneRepository) GetForUser(ctx context.Context, id, userID int) (*models.Drone, error) {
+	query := `
+		SELECT d.id, d.owner_id, d.organization_id, m.role, d.decommissioned_at IS NOT NULL
+		FROM drones d
+		LEFT JOIN organization_members m ON m.organization_id = d.organization_id AND m.user_id = $2
+		WHERE d.id = $1`
+
+	var drone models.Drone
+	var orgID sql.NullInt64
+	var memberRole sql.NullString
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&drone.ID, &drone.OwnerID, &orgID, &memberRole, &drone.Decommissioned)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения дрона: %v", err)
+	}
+
+	switch {
+	case orgID.Valid:
+		drone.UserRole = memberRole.String
+	case drone.OwnerID == userID:
+		drone.UserRole = models.OrgRoleOwner
+	}
+
+	return &drone, nil
+}
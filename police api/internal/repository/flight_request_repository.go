@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"police-api/internal/database"
 	"police-api/internal/models"
@@ -14,14 +16,14 @@ func NewFlightRequestRepository(db *database.DB) *FlightRequestRepository {
 	return &FlightRequestRepository{db: db}
 }
 
-func (r *FlightRequestRepository) Create(req *models.CreateFlightRequestRequest) (*models.FlightRequest, error) {
+func (r *FlightRequestRepository) Create(ctx context.Context, req *models.CreateFlightRequestRequest) (*models.FlightRequest, error) {
 	query := `
         INSERT INTO flightrequest (user_id, drone_id, departure_time, altitude, start_lat, start_lng, end_lat, end_lng, state)
         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'pending')
         RETURNING id, user_id, drone_id, departure_time, altitude, start_lat, start_lng, end_lat, end_lng, state, created_at`
 
 	var flightRequest models.FlightRequest
-	err := r.db.QueryRow(query,
+	err := r.db.QueryRowContext(ctx, query,
 		req.UserID,
 		req.DroneID,
 		req.DepartureTime,
@@ -49,7 +51,7 @@ func (r *FlightRequestRepository) Create(req *models.CreateFlightRequestRequest)
 	}
 
 	usernameQuery := `SELECT full_name FROM users WHERE id = $1`
-	err = r.db.QueryRow(usernameQuery, flightRequest.UserID).Scan(&flightRequest.Username)
+	err = r.db.QueryRowContext(ctx, usernameQuery, flightRequest.UserID).Scan(&flightRequest.Username)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения имени пользователя: %v", err)
 	}
@@ -57,7 +59,7 @@ func (r *FlightRequestRepository) Create(req *models.CreateFlightRequestRequest)
 	return &flightRequest, nil
 }
 
-func (r *FlightRequestRepository) GetPendingRequests() ([]models.FlightRequest, error) {
+func (r *FlightRequestRepository) GetPendingRequests(ctx context.Context) ([]models.FlightRequest, error) {
 	query := `
         SELECT fr.id, fr.user_id, u.full_name, fr.drone_id, fr.departure_time, fr.altitude, 
                fr.start_lat, fr.start_lng, fr.end_lat, fr.end_lng, fr.state, fr.created_at
@@ -66,7 +68,7 @@ func (r *FlightRequestRepository) GetPendingRequests() ([]models.FlightRequest,
         WHERE fr.state = 'pending'
         ORDER BY fr.created_at DESC`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения заявок: %v", err)
 	}
@@ -98,14 +100,24 @@ func (r *FlightRequestRepository) GetPendingRequests() ([]models.FlightRequest,
 	return requests, nil
 }
 
-func (r *FlightRequestRepository) GetUserRequests(userID int) ([]models.FlightRequest, error) {
+// CountPending - число заявок, ожидающих решения.
+func (r *FlightRequestRepository) CountPending(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM flightrequest WHERE state = 'pending'`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчета заявок: %v", err)
+	}
+	return count, nil
+}
+
+func (r *FlightRequestRepository) GetUserRequests(ctx context.Context, userID int) ([]models.FlightRequest, error) {
 	query := `
         SELECT id, user_id, drone_id, departure_time, altitude, start_lat, start_lng, end_lat, end_lng, state, created_at
         FROM flightrequest 
         WHERE user_id = $1
         ORDER BY created_at DESC`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения заявок пользователя: %v", err)
 	}
@@ -136,33 +148,49 @@ func (r *FlightRequestRepository) GetUserRequests(userID int) ([]models.FlightRe
 	return requests, nil
 }
 
-func (r *FlightRequestRepository) UpdateState(id int, state string) error {
-	if state != "approved" && state != "denied" {
-		return fmt.Errorf("неверный статус: %s. Разрешены только 'approved' или 'denied'", state)
+func (r *FlightRequestRepository) UpdateState(ctx context.Context, id int, state string) (*models.FlightRequest, error) {
+	var fromState string
+	switch state {
+	case "approved", "denied":
+		fromState = "pending"
+	case "revoked":
+		fromState = "approved"
+	default:
+		return nil, fmt.Errorf("неверный статус: %s. Разрешены только 'approved', 'denied' или 'revoked'", state)
 	}
 
-	query := `UPDATE flightrequest SET state = $1 WHERE id = $2 AND state = 'pending'`
-	result, err := r.db.Exec(query, state, id)
-	if err != nil {
-		return fmt.Errorf("ошибка обновления статуса заявки: %v", err)
-	}
+	query := `
+        UPDATE flightrequest SET state = $1 WHERE id = $2 AND state = $3
+        RETURNING id, user_id, drone_id, departure_time, altitude, start_lat, start_lng, end_lat, end_lng, state, created_at`
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("ошибка получения количества обновленных строк: %v", err)
+	var flightRequest models.FlightRequest
+	err := r.db.QueryRowContext(ctx, query, state, id, fromState).Scan(
+		&flightRequest.ID,
+		&flightRequest.UserID,
+		&flightRequest.DroneID,
+		&flightRequest.DepartureTime,
+		&flightRequest.Altitude,
+		&flightRequest.StartLat,
+		&flightRequest.StartLng,
+		&flightRequest.EndLat,
+		&flightRequest.EndLng,
+		&flightRequest.State,
+		&flightRequest.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("заявка с ID %d не найдена или находится не в статусе '%s'", id, fromState)
 	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("заявка с ID %d не найдена или уже обработана", id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обновления статуса заявки: %v", err)
 	}
 
-	return nil
+	return &flightRequest, nil
 }
 
-func (r *FlightRequestRepository) CreateActivityLog(userID int, logMessage string) error {
+func (r *FlightRequestRepository) CreateActivityLog(ctx context.Context, userID int, logMessage string) error {
 	query := `INSERT INTO activitylogs (user_id, logs) VALUES ($1, $2)`
 
-	_, err := r.db.Exec(query, userID, logMessage)
+	_, err := r.db.ExecContext(ctx, query, userID, logMessage)
 	if err != nil {
 		return fmt.Errorf("ошибка создания лога активности: %v", err)
 	}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"police-api/internal/database"
+	"police-api/internal/models"
+)
+
+type NotificationRepository struct {
+	db *database.DB
+}
+
+func NewNotificationRepository(db *database.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (r *NotificationRepository) CreateEvent(ctx context.Context, userID int, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации события: %v", err)
+	}
+
+	query := `INSERT INTO notification_events (user_id, event_type, payload) VALUES ($1, $2, $3)`
+
+	_, err = r.db.ExecContext(ctx, query, userID, eventType, data)
+	if err != nil {
+		return fmt.Errorf("ошибка создания события уведомления: %v", err)
+	}
+
+	return nil
+}
+
+// ClaimEvents забирает до limit необработанных событий и блокирует их на lease,
+// чтобы другой обработчик не взял их, пока идет доставка. Событие, которое не
+// отметили обработанным (MarkEventProcessed) до конца lease, например из-за
+// падения сервиса, будет выдано снова.
+func (r *NotificationRepository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]models.NotificationEvent, error) {
+	query := `
+        UPDATE notification_events
+        SET locked_until = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second', attempts = attempts + 1
+        WHERE id IN (
+            SELECT id FROM notification_events
+            WHERE processed_at IS NULL AND (locked_until IS NULL OR locked_until <= CURRENT_TIMESTAMP)
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, user_id, event_type, payload, attempts, created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения событий уведомлений: %v", err)
+	}
+	defer rows.Close()
+
+	var events []models.NotificationEvent
+	for rows.Next() {
+		var event models.NotificationEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.EventType, &event.Payload, &event.Attempts, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования события уведомления: %v", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (r *NotificationRepository) MarkEventProcessed(ctx context.Context, id int) error {
+	query := `UPDATE notification_events SET processed_at = CURRENT_TIMESTAMP, locked_until = NULL WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("ошибка обновления события уведомления: %v", err)
+	}
+
+	return nil
+}
+
+func (r *NotificationRepository) GetUserEmail(ctx context.Context, userID int) (string, error) {
+	var email string
+	err := r.db.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, userID).Scan(&email)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения email пользователя: %v", err)
+	}
+
+	return email, nil
+}
+
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID int) ([]models.NotificationPreference, error) {
+	query := `
+        SELECT user_id, event_type, channel, enabled, target
+        FROM notification_preferences
+        WHERE user_id = $1
+        ORDER BY event_type, channel`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения настроек уведомлений: %v", err)
+	}
+	defer rows.Close()
+
+	var prefs []models.NotificationPreference
+	for rows.Next() {
+		var pref models.NotificationPreference
+		if err := rows.Scan(&pref.UserID, &pref.EventType, &pref.Channel, &pref.Enabled, &pref.Target); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования настройки уведомлений: %v", err)
+		}
+		prefs = append(prefs, pref)
+	}
+
+	return prefs, nil
+}
+
+func (r *NotificationRepository) UpsertPreference(ctx context.Context, pref *models.NotificationPreference) error {
+	query := `
+        INSERT INTO notification_preferences (user_id, event_type, channel, enabled, target)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id, event_type, channel)
+        DO UPDATE SET enabled = EXCLUDED.enabled, target = EXCLUDED.target`
+
+	_, err := r.db.ExecContext(ctx, query, pref.UserID, pref.EventType, pref.Channel, pref.Enabled, pref.Target)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения настройки уведомлений: %v", err)
+	}
+
+	return nil
+}
+
+func (r *NotificationRepository) CreateNotification(ctx context.Context, n *models.Notification) error {
+	payload := n.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+
+	var eventID sql.NullInt64
+	if n.EventID != 0 {
+		eventID = sql.NullInt64{Int64: int64(n.EventID), Valid: true}
+	}
+
+	query := `
+        INSERT INTO notifications (user_id, event_type, title, message, payload, event_id)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (event_id, user_id) WHERE event_id IS NOT NULL DO NOTHING
+        RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, n.UserID, n.EventType, n.Title, n.Message, []byte(payload), eventID).Scan(&n.ID, &n.CreatedAt)
+	if err == sql.ErrNoRows {
+		// Уведомление по этому событию уже создано при прошлой попытке.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка создания уведомления: %v", err)
+	}
+
+	return nil
+}
+
+func (r *NotificationRepository) GetInbox(ctx context.Context, userID int, unreadOnly bool) ([]models.Notification, error) {
+	query := `
+        SELECT id, user_id, event_type, title, message, payload, read_at, created_at
+        FROM notifications
+        WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+        ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, unreadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения уведомлений: %v", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.EventType, &n.Title, &n.Message, &n.Payload, &n.ReadAt, &n.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования уведомления: %v", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID, id int) error {
+	query := `UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND read_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления уведомления: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка получения количества обновленных строк: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("уведомление с ID %d не найдено или уже прочитано", id)
+	}
+
+	return nil
+}
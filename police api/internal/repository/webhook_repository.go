@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"police-api/internal/database"
+	"police-api/internal/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WebhookRepository struct {
+	db *database.DB
+}
+
+func NewWebhookRepository(db *database.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const deliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts,
+               next_attempt_at, last_status_code, last_error, delivered_at, created_at`
+
+func scanDelivery(row interface{ Scan(...interface{}) error }, d *models.WebhookDelivery, extra ...interface{}) error {
+	dest := []interface{}{
+		&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	query := `
+        INSERT INTO webhook_subscriptions (user_id, url, secret, event_types)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, active, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, sub.UserID, sub.URL, sub.Secret, pq.Array(sub.EventTypes)).
+		Scan(&sub.ID, &sub.Active, &sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка создания вебхука: %v", err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) GetUserSubscriptions(ctx context.Context, userID int) ([]models.WebhookSubscription, error) {
+	query := `
+        SELECT id, user_id, url, event_types, active, created_at
+        FROM webhook_subscriptions
+        WHERE user_id = $1
+        ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения вебхуков: %v", err)
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		var sub models.WebhookSubscription
+		err := rows.Scan(&sub.ID, &sub.UserID, &sub.URL, pq.Array(&sub.EventTypes), &sub.Active, &sub.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования вебхука: %v", err)
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, userID, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления вебхука: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка получения количества удаленных строк: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("вебхук с ID %d не найден", id)
+	}
+
+	return nil
+}
+
+// EnqueueDeliveries создает доставки события для всех подходящих подписок:
+// подписок владельца события или, для публичных событий, всех подписчиков.
+func (r *WebhookRepository) EnqueueDeliveries(ctx context.Context, event models.NotificationEvent, public bool) error {
+	query := `
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+        SELECT id, $1, $2, $3
+        FROM webhook_subscriptions
+        WHERE active AND $2 = ANY(event_types) AND (user_id = $4 OR $5)
+        ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	_, err := r.db.ExecContext(ctx, query, event.ID, event.EventType, []byte(event.Payload), event.UserID, public)
+	if err != nil {
+		return fmt.Errorf("ошибка постановки доставок вебхуков: %v", err)
+	}
+
+	return nil
+}
+
+// ClaimDueDeliveries забирает доставки, время попытки которых наступило, и
+// откладывает их следующую попытку на lease, чтобы другой обработчик не взял
+// их повторно, пока идет отправка.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingWebhookDelivery, error) {
+	query := `
+        UPDATE webhook_deliveries d
+        SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+        FROM webhook_subscriptions s
+        WHERE s.id = d.subscription_id AND d.id IN (
+            SELECT id FROM webhook_deliveries
+            WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+                  d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at,
+                  s.url, s.secret`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения доставок вебхуков: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.PendingWebhookDelivery
+	for rows.Next() {
+		var d models.PendingWebhookDelivery
+		if err := scanDelivery(rows, &d.WebhookDelivery, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования доставки вебхука: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, id, statusCode int) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = 'delivered', attempts = attempts + 1, last_status_code = $1,
+            last_error = NULL, delivered_at = CURRENT_TIMESTAMP, next_attempt_at = NULL
+        WHERE id = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, statusCode, id); err != nil {
+		return fmt.Errorf("ошибка обновления доставки вебхука: %v", err)
+	}
+
+	return nil
+}
+
+// MarkAttemptFailed записывает неудачную попытку. Если nextAttempt равен nil,
+// доставка считается окончательно проваленной.
+func (r *WebhookRepository) MarkAttemptFailed(ctx context.Context, id int, statusCode *int, errMsg string, nextAttempt *time.Time) error {
+	status := models.DeliveryStatusPending
+	if nextAttempt == nil {
+		status = models.DeliveryStatusFailed
+	}
+
+	query := `
+        UPDATE webhook_deliveries
+        SET status = $1, attempts = attempts + 1, last_status_code = $2, last_error = $3, next_attempt_at = $4
+        WHERE id = $5`
+
+	if _, err := r.db.ExecContext(ctx, query, status, statusCode, errMsg, nextAttempt, id); err != nil {
+		return fmt.Errorf("ошибка обновления доставки вебхука: %v", err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) GetDeliveries(ctx context.Context, userID, subscriptionID int) ([]models.WebhookDelivery, error) {
+	query := `
+        SELECT d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+               d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at
+        FROM webhook_deliveries d
+        JOIN webhook_subscriptions s ON s.id = d.subscription_id
+        WHERE s.user_id = $1 AND d.subscription_id = $2
+        ORDER BY d.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения журнала доставок: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования доставки вебхука: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver создает новую доставку с тем же содержимым; исходная запись
+// остается в журнале без изменений.
+func (r *WebhookRepository) Redeliver(ctx context.Context, userID, deliveryID int) (*models.WebhookDelivery, error) {
+	query := `
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+        SELECT d.subscription_id, d.event_id, d.event_type, d.payload
+        FROM webhook_deliveries d
+        JOIN webhook_subscriptions s ON s.id = d.subscription_id
+        WHERE d.id = $1 AND s.user_id = $2
+        RETURNING ` + deliveryColumns
+
+	var d models.WebhookDelivery
+	err := scanDelivery(r.db.QueryRowContext(ctx, query, deliveryID, userID), &d)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("доставка с ID %d не найдена", deliveryID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка повторной доставки вебхука: %v", err)
+	}
+
+	return &d, nil
+}
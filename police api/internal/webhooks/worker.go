@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"common/logging"
+	"police-api/internal/models"
+	"police-api/internal/netguard"
+	"police-api/internal/repository"
+)
+
+const (
+	batchSize    = 50
+	maxAttempts  = 8
+	baseDelay    = 10 * time.Second
+	maxDelay     = time.Hour
+	claimLease   = time.Minute
+	errorBodyMax = 512
+)
+
+// publicEvents рассылаются всем подписчикам, а не только владельцу события.
+var publicEvents = map[string]bool{
+	models.EventZoneCreated: true,
+}
+
+// Worker превращает события из общей очереди в доставки вебхуков и
+// отправляет их с подписью и повторами по экспоненциальной задержке.
+// Адрес получателя проверяется при каждом соединении: хост подписки мог
+// начать указывать во внутреннюю сеть после ее создания.
+type Worker struct {
+	repo     *repository.WebhookRepository
+	client   *http.Client
+	interval time.Duration
+}
+
+func NewWorker(repo *repository.WebhookRepository, interval time.Duration) *Worker {
+	return &Worker{
+		repo:     repo,
+		client:   netguard.NewClient(10 * time.Second),
+		interval: interval,
+	}
+}
+
+// HandleEvent вызывается диспетчером уведомлений для каждого события очереди.
+func (w *Worker) HandleEvent(ctx context.Context, event models.NotificationEvent) {
+	if err := w.repo.EnqueueDeliveries(ctx, event, publicEvents[event.EventType]); err != nil {
+		slog.ErrorContext(ctx, "Ошибка постановки вебхуков", "event_id", event.ID, "error", err)
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Начатая пачка доводится до конца и при остановке сервиса.
+			w.processBatch(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+func (w *Worker) processBatch(ctx context.Context) {
+	deliveries, err := w.repo.ClaimDueDeliveries(ctx, batchSize, claimLease)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка чтения очереди вебхуков", "error", err)
+		return
+	}
+
+	for _, d := range deliveries {
+		w.deliver(ctx, d)
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, d models.PendingWebhookDelivery) {
+	ctx = logging.With(ctx, "delivery_id", d.ID)
+
+	statusCode, err := w.send(ctx, d)
+	if err == nil {
+		if err := w.repo.MarkDelivered(ctx, d.ID, statusCode); err != nil {
+			slog.ErrorContext(ctx, "Ошибка сохранения результата доставки", "error", err)
+		}
+		return
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	var next *time.Time
+	if attempt := d.Attempts + 1; attempt < maxAttempts {
+		t := time.Now().Add(retryDelay(attempt))
+		next = &t
+	}
+
+	if err := w.repo.MarkAttemptFailed(ctx, d.ID, code, err.Error(), next); err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения результата доставки", "error", err)
+	}
+}
+
+func (w *Worker) send(ctx context.Context, d models.PendingWebhookDelivery) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         d.ID,
+		"event_id":   d.EventID,
+		"event_type": d.EventType,
+		"created_at": d.CreatedAt,
+		"data":       d.Payload,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сериализации: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", strconv.Itoa(d.ID))
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set(SignatureHeader, Sign(d.Secret, time.Now().Unix(), body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отправки: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodyMax))
+		return resp.StatusCode, fmt.Errorf("получатель вернул статус %d: %s", resp.StatusCode, snippet)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// retryDelay - задержка перед попыткой номер attempt+1: 10с, 20с, 40с, ... не более часа.
+func retryDelay(attempt int) time.Duration {
+	delay := baseDelay << (attempt - 1)
+	if delay > maxDelay || delay <= 0 {
+		return maxDelay
+	}
+	return delay
+}
@@ -1,39 +1,132 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"common/gatewayauth"
+	"common/health"
+	"common/logging"
+	"common/metrics"
+	"common/requestid"
+	"common/tracing"
+	"police-api/internal/config"
 	"police-api/internal/database"
 	"police-api/internal/handlers"
+	"police-api/internal/notifications"
 	"police-api/internal/repository"
+	"police-api/internal/webhooks"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
 func main() {
+	configFile := flag.String("config", "", "файл с переменными окружения (по умолчанию .env, если есть)")
+	printConfig := flag.Bool("print-config", false, "вывести конфигурацию без секретов и выйти")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка конфигурации:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	if err := logging.Setup("police", cfg.LogLevel); err != nil {
+		logging.Fatal("Ошибка настройки логирования", "error", err)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "police", cfg.TracesExporter)
+	if err != nil {
+		logging.Fatal("Ошибка настройки трассировки", "error", err)
+	}
+
 	// Подключение к базе данных
-	db, err := database.NewConnection()
+	db, err := database.NewConnection(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatal("Ошибка подключения к базе данных:", err)
+		logging.Fatal("Ошибка подключения к базе данных", "error", err)
 	}
 	defer db.Close()
 
 	// Создание таблиц
 	if err := db.CreateTables(); err != nil {
-		log.Fatal("Ошибка создания таблиц:", err)
+		logging.Fatal("Ошибка создания таблиц", "error", err)
 	}
 
 	// Инициализация репозитория и обработчиков
 	flightRequestRepo := repository.NewFlightRequestRepository(db)
-	flightRequestHandler := handlers.NewFlightRequestHandler(flightRequestRepo)
+	droneRepo := repository.NewDroneRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+
+	// Уведомления и вебхуки
+	dispatcher := notifications.NewDispatcher(notificationRepo, 2*time.Second,
+		notifications.NewInboxChannel(notificationRepo),
+		notifications.NewEmailChannel(cfg.SMTPAddr, cfg.SMTPFrom),
+		notifications.NewWebhookChannel(),
+	)
+
+	webhookRepo := repository.NewWebhookRepository(db)
+	webhookWorker := webhooks.NewWorker(webhookRepo, 2*time.Second)
+	dispatcher.AddHandler(webhookWorker)
+
+	// SIGTERM посылают оркестраторы, SIGINT - Ctrl+C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		dispatcher.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		webhookWorker.Run(ctx)
+	}()
+
+	flightRequestHandler := handlers.NewFlightRequestHandler(flightRequestRepo, droneRepo, dispatcher)
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo)
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo)
+
+	metrics.RegisterDB(db.DB, "police")
+	metrics.RegisterGauge("police_pending_flight_requests", "Число заявок на полет, ожидающих решения.", func() float64 {
+		count, err := flightRequestRepo.CountPending(context.Background())
+		if err != nil {
+			slog.Error("Ошибка подсчета заявок для метрик", "error", err)
+			return math.NaN()
+		}
+		return float64(count)
+	})
 
 	// Настройка маршрутов
 	router := mux.NewRouter()
+	router.Use(otelmux.Middleware("police"), requestid.Middleware, metrics.Middleware)
+
+	// Проверки состояния для шлюза и оркестратора, без подписи шлюза
+	healthHandler := health.New("police")
+	healthHandler.AddCheck("database", db.PingContext)
+	router.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	// API маршруты
 	api := router.PathPrefix("/api").Subrouter()
 	api.Use(jsonMiddleware)
+	// Принимаются только запросы, подписанные шлюзом
+	api.Use(gatewayauth.NewVerifier(cfg.GatewayJWKSURL, handlers.WriteError).Middleware)
 
 	// Маршруты для заявок
 	api.HandleFunc("/requests/create", flightRequestHandler.CreateRequest).Methods("POST")
@@ -41,9 +134,57 @@ func main() {
 	api.HandleFunc("/requests/user", flightRequestHandler.GetUserRequests).Methods("POST")
 	api.HandleFunc("/requests/update", flightRequestHandler.UpdateRequestState).Methods("POST")
 
+	// Маршруты для уведомлений
+	api.HandleFunc("/notifications/inbox", notificationHandler.GetInbox).Methods("POST")
+	api.HandleFunc("/notifications/read", notificationHandler.MarkRead).Methods("POST")
+	api.HandleFunc("/notifications/preferences", notificationHandler.GetPreferences).Methods("POST")
+	api.HandleFunc("/notifications/preferences/update", notificationHandler.UpdatePreference).Methods("POST")
+
+	// Маршруты для вебхуков
+	api.HandleFunc("/webhooks/create", webhookHandler.CreateWebhook).Methods("POST")
+	api.HandleFunc("/webhooks/list", webhookHandler.GetUserWebhooks).Methods("POST")
+	api.HandleFunc("/webhooks/delete", webhookHandler.DeleteWebhook).Methods("POST")
+	api.HandleFunc("/webhooks/deliveries", webhookHandler.GetDeliveries).Methods("POST")
+	api.HandleFunc("/webhooks/redeliver", webhookHandler.Redeliver).Methods("POST")
+
 	// Запуск сервера
-	log.Println("Сервер запущен на порту :8081")
-	log.Fatal(http.ListenAndServe(":8081", router))
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	slog.Info("Сервер запущен", "port", cfg.Port)
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("Ошибка запуска сервера", "error", err)
+	case <-ctx.Done():
+	}
+
+	// Остановка: дожидаемся начатых запросов и пачек уведомлений и вебхуков.
+	slog.Info("Остановка сервера", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Не все запросы завершились до остановки", "error", err)
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		slog.Error("Фоновые задачи не завершились до остановки")
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Ошибка отправки спанов", "error", err)
+	}
+	slog.Info("Сервер остановлен")
 }
 
 func jsonMiddleware(next http.Handler) http.Handler {